@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jamrig/chippy/internal/assembler"
+)
+
+func asmCommand(args []string) error {
+	fs := flag.NewFlagSet("asm", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chippy asm [flags] <source.8o>")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "output ROM `file`, the source name with .ch8 if not set")
+	symbols := fs.String("symbols", "", "symbol `file` to write for the debugger")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("must include source path")
+	}
+
+	sourceFile := fs.Arg(0)
+
+	src, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return err
+	}
+
+	program, err := assembler.Assemble(string(src))
+	if err != nil {
+		return fmt.Errorf("%s:%w", sourceFile, err)
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(sourceFile, filepath.Ext(sourceFile)) + ".ch8"
+	}
+
+	if err := os.WriteFile(out, program.ROM, 0o644); err != nil {
+		return err
+	}
+
+	if *symbols == "" {
+		return nil
+	}
+
+	f, err := os.Create(*symbols)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return program.Symbols.Write(f)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jamrig/chippy/internal/assembler"
+	"github.com/jamrig/chippy/internal/debugger"
+)
+
+func debugCommand(args []string) error {
+	f, fs := newRunFlags("debug")
+	symbolsFile := fs.String("symbols", "", "symbol `file` from chippy asm with labels and breakpoints")
+	fs.Parse(args)
+
+	e, err := f.emulator(fs)
+	if e == nil || err != nil {
+		return err
+	}
+
+	d := debugger.New(e)
+
+	if *symbolsFile != "" {
+		sf, err := os.Open(*symbolsFile)
+		if err != nil {
+			return err
+		}
+		defer sf.Close()
+
+		symbols, err := assembler.ReadSymbols(sf)
+		if err != nil {
+			return err
+		}
+
+		d.AddSymbols(symbols)
+	}
+
+	return d.Run(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jamrig/chippy/internal/disasm"
+	"github.com/jamrig/chippy/internal/emulator"
+)
+
+func disasmCommand(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chippy disasm [flags] <program>")
+		fs.PrintDefaults()
+	}
+	platform := fs.String("platform", "vip", "platform profile (vip, chip-48, schip-legacy, schip-modern, xo-chip)")
+	output := fs.String("o", "", "output `file`, stdout if not set")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errors.New("must include program path")
+	}
+
+	config, err := emulator.GetProfile(*platform)
+	if err != nil {
+		return err
+	}
+
+	rom, err := emulator.LoadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	return disasm.New(config).Disassemble(rom).Write(out)
+}
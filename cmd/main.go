@@ -1,25 +1,51 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
-
-	"github.com/jamrig/chippy/internal/emulator"
+	"sort"
 )
 
+// commands contains the subcommands, each taking the remaining arguments.
+var commands = map[string]func(args []string) error{
+	"run":    runCommand,
+	"debug":  debugCommand,
+	"disasm": disasmCommand,
+	"asm":    asmCommand,
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("must include font path")
-	}
+	args := os.Args[1:]
 
-	if len(os.Args) < 3 {
-		log.Fatal("must include program path")
+	// Running a program is the default when no subcommand is given.
+	name := "run"
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name = args[0]
+			args = args[1:]
+		} else if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			usage()
+			return
+		}
 	}
 
-	e, err := emulator.New(os.Args[1], os.Args[2])
-	if err != nil {
+	if err := commands[name](args); err != nil {
 		log.Fatal(err)
 	}
+}
+
+func usage() {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	e.Start()
+	fmt.Fprintln(os.Stderr, "usage: chippy [command] [flags] <args>")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+	fmt.Fprintln(os.Stderr, "run 'chippy <command> -h' for the flags of a command")
 }
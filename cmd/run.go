@@ -0,0 +1,427 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamrig/chippy/internal/debugger"
+	"github.com/jamrig/chippy/internal/emulator"
+)
+
+// runFlags are the flags of the run command.
+type runFlags struct {
+	config      string
+	platform    string
+	romDB       string
+	font        string
+	ips         int
+	scale       int
+	mode        string
+	foreground  int
+	background  int
+	theme       string
+	colors      string
+	color       string
+	keymap      string
+	seed        int64
+	rewind      int
+	capture     int
+	printConfig bool
+	loadState   string
+	wav         string
+	quirks      map[string]*bool
+	// movie is the movie being played back, whose config replaces the resolved config.
+	movie *emulator.Movie
+}
+
+// quirkFlags maps the quirk flag names to their description.
+var quirkFlags = map[string]string{
+	"quirk-shift":          "assign Vy to Vx before shifting",
+	"quirk-jump":           "use Vx rather than V0 for BNNN",
+	"quirk-index-overflow": "set VF on I overflow in FX1E",
+	"quirk-memory-index":   "increment I on FX55 and FX65",
+	"quirk-vf-reset":       "reset VF on 8XY1, 8XY2 and 8XY3",
+	"quirk-display-wait":   "wait for the display refresh before drawing",
+	"quirk-clip":           "clip sprites at the screen edges rather than wrapping",
+}
+
+// headlessFlags are the flags of the run command for running without a window.
+type headlessFlags struct {
+	headless     bool
+	frames       int
+	instructions uint64
+	untilPC      string
+	time         time.Duration
+	screenshot   string
+	gif          string
+	gifStart     time.Duration
+	gifLength    time.Duration
+}
+
+// movieFlags are the flags of the run command for recording and playing back movies.
+type movieFlags struct {
+	record           string
+	play             string
+	checksumInterval int
+}
+
+func runCommand(args []string) error {
+	f, fs := newRunFlags("run")
+	h := &headlessFlags{}
+	fs.BoolVar(&h.headless, "headless", false, "run without a window until a limit is reached")
+	fs.IntVar(&h.frames, "frames", 0, "stop a headless run after `n` frames")
+	fs.Uint64Var(&h.instructions, "instructions", 0, "stop a headless run after `n` instructions")
+	fs.StringVar(&h.untilPC, "until-pc", "", "stop a headless run when the PC reaches the hex `address`")
+	fs.DurationVar(&h.time, "time", 0, "stop a headless run after the wall-clock `duration`")
+	fs.StringVar(&h.screenshot, "screenshot", "", "write the final display of a headless run to the PNG or text `file`")
+	fs.StringVar(&h.gif, "gif", "", "record a headless run to the GIF `file`")
+	fs.DurationVar(&h.gifStart, "gif-start", 0, "emulated `time` at which the GIF recording starts")
+	fs.DurationVar(&h.gifLength, "gif-length", 0, "emulated `duration` of the GIF recording, 0 until the run stops")
+	m := &movieFlags{}
+	fs.StringVar(&m.record, "record", "", "record the keypad input to the movie `file`")
+	fs.StringVar(&m.play, "play", "", "play back the movie `file`, using its config")
+	fs.IntVar(&m.checksumInterval, "checksum-interval", 60, "frames between the display checksums of a recorded movie, 0 for none")
+	fs.Parse(args)
+
+	switch {
+	case m.record != "" && m.play != "":
+		return errors.New("cannot both --record and --play a movie")
+	case m.play != "" && f.loadState != "":
+		return errors.New("cannot --load-state when playing a movie, it starts from its own state")
+	case m.play != "":
+		movie, err := emulator.LoadMovieFile(m.play)
+		if err != nil {
+			return err
+		}
+
+		f.movie = movie
+	}
+
+	var options []emulator.Option
+	if !h.headless {
+		options = append(options, terminalFrontend, emulator.WithAudio(emulator.NewBellSink(os.Stdout)))
+	}
+
+	e, err := f.emulator(fs, options...)
+	if e == nil || err != nil {
+		return err
+	}
+
+	switch {
+	case m.record != "":
+		err = e.RecordMovie(f.loadState != "", m.checksumInterval)
+	case f.movie != nil:
+		err = e.PlayMovie(f.movie)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if h.headless {
+		err = h.run(e)
+		if cerr := e.Audio.Close(); err == nil {
+			err = cerr
+		}
+	} else {
+		err = e.Start()
+
+		var fault *emulator.Fault
+		if errors.As(err, &fault) && fault.Policy == emulator.PolicyBreak {
+			d := debugger.New(e)
+			d.Reason = fault.Error()
+			err = d.Run(os.Stdin, os.Stdout)
+		}
+	}
+
+	if m.record != "" {
+		e.Movie.Stop(e)
+		if serr := e.Movie.Movie.SaveFile(m.record); err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+// run runs the emulator headless and writes the screenshot and GIF if requested.
+func (h *headlessFlags) run(e *emulator.Emulator) error {
+	limits := emulator.HeadlessLimits{
+		Frames:       h.frames,
+		Instructions: h.instructions,
+		Time:         h.time,
+	}
+
+	if h.untilPC != "" {
+		pc, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(h.untilPC), "0x"), 16, 16)
+		if err != nil {
+			return fmt.Errorf("invalid --until-pc %q", h.untilPC)
+		}
+
+		addr := uint16(pc)
+		limits.UntilPC = &addr
+	}
+
+	e.CPU.OnFault = func(f *emulator.Fault) {
+		fmt.Fprintf(os.Stderr, "fault: %s\n", f)
+	}
+
+	if h.gif != "" {
+		frequency := time.Duration(e.Config.Display.Frequency)
+		e.GIF = emulator.NewGIFRecorder(e.Config, int(h.gifStart*frequency/time.Second), int(h.gifLength*frequency/time.Second))
+	}
+
+	reason, err := e.RunHeadless(limits)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "stopped (%s) after %d frames, %d instructions, PC 0x%03X\n",
+		reason, e.Display.Frame, e.CPU.Cycles, e.CPU.PC)
+
+	if h.gif != "" {
+		if err := e.GIF.SaveFile(h.gif); err != nil {
+			return err
+		}
+	}
+
+	if h.screenshot != "" {
+		return e.Display.SaveScreenshot(h.screenshot, e.Config.Capture.Scale)
+	}
+
+	return nil
+}
+
+// newRunFlags returns the flags for setting up an emulator, shared by the commands which run a program.
+func newRunFlags(name string) (*runFlags, *flag.FlagSet) {
+	f := &runFlags{quirks: map[string]*bool{}}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: chippy %s [flags] <program>\n", name)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&f.config, "config", "", "YAML or JSON config `file`")
+	fs.StringVar(&f.platform, "platform", "", "platform profile (vip, chip-48, schip-legacy, schip-modern, xo-chip)")
+	fs.StringVar(&f.romDB, "romdb", emulator.DefaultROMDatabasePath(), "JSON ROM database `file`")
+	fs.StringVar(&f.font, "font", "", "font `file` to use instead of the built-in font")
+	fs.IntVar(&f.ips, "ips", 0, "instructions per second")
+	fs.IntVar(&f.scale, "scale", 0, "times each pixel is repeated, 0 to fit the terminal")
+	fs.StringVar(&f.mode, "mode", "", "terminal render mode (block, half-block, braille, kitty, sixel)")
+	fs.IntVar(&f.foreground, "fg", -1, "256-colour palette index of set pixels, -1 to use the theme colour")
+	fs.IntVar(&f.background, "bg", -1, "256-colour palette index of unset pixels, -1 to use the theme colour")
+	fs.StringVar(&f.theme, "theme", "", "colour theme ("+strings.Join(emulator.ThemeNames(), ", ")+")")
+	fs.StringVar(&f.colors, "colors", "", "comma-separated `#rrggbb` colours of unset pixels, plane 1, plane 2 and both planes")
+	fs.StringVar(&f.color, "color", "", "terminal colours (auto, truecolor, 256)")
+	fs.StringVar(&f.keymap, "keymap", "", "keymap as comma-separated `key=hex` pairs, e.g. 1=1,q=4")
+	fs.Int64Var(&f.seed, "seed", 0, "random number generator seed, from the current time if not set")
+	fs.IntVar(&f.rewind, "rewind-budget", 0, "rewind buffer size in bytes, 0 disables rewinding")
+	fs.IntVar(&f.capture, "capture-scale", 0, "image pixels per low-resolution pixel in screenshots and GIFs")
+	fs.BoolVar(&f.printConfig, "print-config", false, "print the config that would be used and exit")
+	fs.StringVar(&f.loadState, "load-state", "", "save state `file` to start from")
+	fs.StringVar(&f.wav, "wav", "", "record the sound to the WAV `file` instead of ringing the terminal bell")
+	for name, usage := range quirkFlags {
+		f.quirks[name] = fs.Bool(name, false, usage)
+	}
+
+	return f, fs
+}
+
+// emulator returns the emulator for the program argument of the parsed flags, with the
+// frontend set by the options. --wav replaces the audio sink of the options.
+// If --print-config is set the config is printed instead and no emulator is returned.
+func (f *runFlags) emulator(fs *flag.FlagSet, options ...emulator.Option) (*emulator.Emulator, error) {
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return nil, errors.New("must include program path")
+	}
+
+	programFile := fs.Arg(0)
+
+	var config *emulator.Config
+	var err error
+	if f.movie != nil {
+		config = f.movie.Config.Clone()
+	} else {
+		config, err = f.resolveConfig(fs, programFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if f.printConfig {
+		out, err := config.YAML()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = os.Stdout.Write(out)
+
+		return nil, err
+	}
+
+	if f.wav != "" {
+		options = append(options, emulator.WithAudio(emulator.NewWAVSink(config.Audio, f.wav)))
+	}
+
+	e, err := emulator.New(config, programFile, f.font, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.loadState != "" {
+		if err := e.LoadStateFile(f.loadState); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// terminalFrontend draws the display and reads the keypad in a terminal Window.
+func terminalFrontend(e *emulator.Emulator) {
+	w := emulator.NewWindow(e.Config.Input, e.Config.Display)
+	emulator.WithRenderer(w)(e)
+	emulator.WithInput(w)(e)
+}
+
+// resolveConfig builds the config from the platform, ROM database, config file and
+// flags, with each taking priority over the previous.
+func (f *runFlags) resolveConfig(fs *flag.FlagSet, programFile string) (*emulator.Config, error) {
+	config, err := f.baseConfig(programFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.config != "" {
+		config, err = emulator.LoadConfigFile(f.config, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	quirks := map[string]*bool{
+		"quirk-shift":          &config.CPU.InstructionAssignBeforeShift,
+		"quirk-jump":           &config.CPU.InstructionUseVxForOffset,
+		"quirk-index-overflow": &config.CPU.InstructionOverflowAddIndex,
+		"quirk-memory-index":   &config.CPU.InstructionModifyIndexOnStoreAndLoad,
+		"quirk-vf-reset":       &config.CPU.InstructionResetFlagOnLogic,
+		"quirk-display-wait":   &config.CPU.InstructionWaitForDisplay,
+		"quirk-clip":           &config.CPU.InstructionClipSprites,
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == "keymap" {
+			keymap, kerr := parseKeymap(f.keymap)
+			if kerr != nil {
+				err = kerr
+				return
+			}
+
+			config.Input.Keymap = keymap
+		}
+
+		switch fl.Name {
+		case "ips":
+			config.CPU.InstructionTimerFrequency = f.ips
+		case "scale":
+			config.Display.Scale = f.scale
+		case "mode":
+			config.Display.Mode = emulator.RenderMode(f.mode)
+		case "fg":
+			config.Display.Foreground = f.foreground
+		case "bg":
+			config.Display.Background = f.background
+		case "theme":
+			config.Display.Theme = f.theme
+		case "colors":
+			config.Display.Colors = strings.Split(f.colors, ",")
+		case "color":
+			config.Display.Color = emulator.ColorMode(f.color)
+		case "seed":
+			config.CPU.Seed = &f.seed
+		case "rewind-budget":
+			config.Rewind.Budget = f.rewind
+		case "capture-scale":
+			config.Capture.Scale = f.capture
+		}
+
+		if quirk, ok := quirks[fl.Name]; ok {
+			*quirk = *f.quirks[fl.Name]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// baseConfig returns the config for the platform flag if set, otherwise the ROM database
+// entry for the program, otherwise a profile chosen from the program file extension.
+func (f *runFlags) baseConfig(programFile string) (*emulator.Config, error) {
+	if f.platform != "" {
+		return emulator.GetProfile(f.platform)
+	}
+
+	config := emulator.CHIP8Config
+	switch strings.ToLower(filepath.Ext(programFile)) {
+	case ".sc8":
+		config = emulator.SCHIPConfig
+	case ".xo8":
+		config = emulator.XOCHIPConfig
+	}
+
+	var db *emulator.ROMDatabase
+	if f.romDB != "" {
+		if _, err := os.Stat(f.romDB); err == nil {
+			db, err = emulator.LoadROMDatabase(f.romDB)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	program, err := emulator.LoadFile(programFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err = db.ConfigFor(program, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Clone(), nil
+}
+
+// parseKeymap parses comma-separated key=hex pairs into a keymap.
+func parseKeymap(s string) (map[string]byte, error) {
+	keymap := map[string]byte{}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("keymap entry %q must be key=hex", pair)
+		}
+
+		value, err := strconv.ParseUint(key, 16, 8)
+		if err != nil || value >= emulator.KeyCount {
+			return nil, fmt.Errorf("keymap entry %q must map to a key from 0 to F", pair)
+		}
+
+		keymap[strings.ToLower(name)] = byte(value)
+	}
+
+	return keymap, nil
+}
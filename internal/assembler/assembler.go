@@ -0,0 +1,470 @@
+package assembler
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fixupKind is the kind of value patched once a label is defined.
+type fixupKind int
+
+const (
+	// fixupNNN patches the low 12 bits of an instruction.
+	fixupNNN fixupKind = iota
+	// fixupWord patches a full 16-bit word.
+	fixupWord
+	// fixupByte patches a single byte.
+	fixupByte
+)
+
+// fixup is a forward reference to a label.
+type fixup struct {
+	Kind    fixupKind
+	Address uint16
+	Token   Token
+}
+
+// macro is a :macro definition.
+type macro struct {
+	Params []string
+	Body   []Token
+}
+
+// flow is an open if/else or loop block.
+type flow struct {
+	// Kind is "if", "else" or "loop".
+	Kind  string
+	Token Token
+	// Jump is the address of the jump to patch at the end of an if or else block.
+	Jump uint16
+	// Start is the address of the start of a loop.
+	Start uint16
+	// Breaks are the addresses of the while jumps to patch at the end of a loop.
+	Breaks []uint16
+}
+
+// Program is the result of assembling a source file.
+type Program struct {
+	// ROM is the binary, loaded at the origin.
+	ROM []byte
+	// Symbols are the labels and breakpoints of the program.
+	Symbols *Symbols
+}
+
+// Assembler assembles Octo source into a CHIP-8 binary.
+type Assembler struct {
+	// Origin is the address the binary is loaded at.
+	Origin uint16
+
+	tokens      []Token
+	last        Token
+	out         []byte
+	here        uint16
+	labels      map[string]uint16
+	consts      map[string]int
+	aliases     map[string]byte
+	macros      map[string]*macro
+	breakpoints map[string]uint16
+	fixups      []fixup
+	flows       []*flow
+}
+
+// New returns a new Assembler for binaries loaded at the origin.
+func New(origin uint16) *Assembler {
+	return &Assembler{
+		Origin:      origin,
+		here:        origin,
+		out:         []byte{},
+		labels:      map[string]uint16{},
+		consts:      map[string]int{},
+		aliases:     map[string]byte{},
+		macros:      map[string]*macro{},
+		breakpoints: map[string]uint16{},
+		fixups:      []fixup{},
+		flows:       []*flow{},
+	}
+}
+
+// Assemble assembles the source with the default CHIP-8 origin of 0x200.
+func Assemble(src string) (*Program, error) {
+	return New(0x200).Assemble(src)
+}
+
+// Assemble assembles the source into a program.
+func (a *Assembler) Assemble(src string) (*Program, error) {
+	a.tokens = Tokenize(src)
+
+	// Like Octo, execution begins at main, so jump to it unless it is the first thing defined.
+	if a.definesMain() && !(len(a.tokens) >= 2 && a.tokens[0].Text == ":" && a.tokens[1].Text == "main") {
+		a.emitJump(Token{Text: "main", Line: 1, Col: 1})
+	}
+
+	for len(a.tokens) > 0 {
+		if err := a.statement(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(a.flows) > 0 {
+		f := a.flows[len(a.flows)-1]
+		return nil, errorAt(f.Token, "%s is missing its closing %s", f.Token.Text, map[string]string{"if": "end", "else": "end", "loop": "again"}[f.Kind])
+	}
+
+	for _, f := range a.fixups {
+		addr, ok := a.labels[f.Token.Text]
+		if !ok {
+			return nil, errorAt(f.Token, "undefined label %q", f.Token.Text)
+		}
+
+		i := int(f.Address - a.Origin)
+		switch f.Kind {
+		case fixupNNN:
+			if err := jumpTarget(f.Token, addr); err != nil {
+				return nil, err
+			}
+
+			a.out[i] = a.out[i]&0xF0 | byte(addr>>8)
+			a.out[i+1] = byte(addr)
+		case fixupWord:
+			a.out[i] = byte(addr >> 8)
+			a.out[i+1] = byte(addr)
+		case fixupByte:
+			a.out[i] = byte(addr)
+		}
+	}
+
+	return &Program{
+		ROM:     a.out,
+		Symbols: a.symbols(),
+	}, nil
+}
+
+func (a *Assembler) definesMain() bool {
+	for i := 0; i+1 < len(a.tokens); i++ {
+		if a.tokens[i].Text == ":" && a.tokens[i+1].Text == "main" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *Assembler) symbols() *Symbols {
+	s := &Symbols{}
+
+	for name, addr := range a.labels {
+		s.Labels = append(s.Labels, Symbol{Name: name, Address: addr})
+	}
+
+	for name, addr := range a.breakpoints {
+		s.Breakpoints = append(s.Breakpoints, Symbol{Name: name, Address: addr})
+	}
+
+	sortSymbols(s.Labels)
+	sortSymbols(s.Breakpoints)
+
+	return s
+}
+
+func sortSymbols(symbols []Symbol) {
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Address != symbols[j].Address {
+			return symbols[i].Address < symbols[j].Address
+		}
+		return symbols[i].Name < symbols[j].Name
+	})
+}
+
+// next returns the next token, expanding macro invocations.
+func (a *Assembler) next() (Token, error) {
+	if err := a.expand(); err != nil {
+		return Token{}, err
+	}
+
+	if len(a.tokens) == 0 {
+		return Token{}, errorAt(a.last, "unexpected end of input after %q", a.last.Text)
+	}
+
+	t := a.tokens[0]
+	a.tokens = a.tokens[1:]
+	a.last = t
+
+	return t, nil
+}
+
+// peek returns the next token without consuming it, expanding macro invocations, and
+// false at the end of input.
+func (a *Assembler) peek() (Token, bool, error) {
+	if err := a.expand(); err != nil {
+		return Token{}, false, err
+	}
+
+	if len(a.tokens) == 0 {
+		return Token{}, false, nil
+	}
+
+	return a.tokens[0], true, nil
+}
+
+// expand replaces macro invocations at the front of the tokens with their bodies until
+// the next token is not a macro.
+func (a *Assembler) expand() error {
+	for len(a.tokens) > 0 {
+		t := a.tokens[0]
+
+		m, ok := a.macros[t.Text]
+		if !ok {
+			return nil
+		}
+
+		a.tokens = a.tokens[1:]
+		a.last = t
+
+		if len(a.tokens) < len(m.Params) {
+			return errorAt(t, "macro %s needs %d arguments", t.Text, len(m.Params))
+		}
+
+		args := map[string]string{}
+		for i, p := range m.Params {
+			args[p] = a.tokens[i].Text
+		}
+		a.tokens = a.tokens[len(m.Params):]
+
+		body := make([]Token, len(m.Body))
+		for i, bt := range m.Body {
+			body[i] = bt
+			if arg, ok := args[bt.Text]; ok {
+				body[i].Text = arg
+			}
+		}
+
+		a.tokens = append(body, a.tokens...)
+	}
+
+	return nil
+}
+
+// expect returns an error unless the next token is the text.
+func (a *Assembler) expect(text string) error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if t.Text != text {
+		return errorAt(t, "expected %q, got %q", text, t.Text)
+	}
+
+	return nil
+}
+
+// block returns the tokens between a { and its matching }, which must be the next token.
+func (a *Assembler) block() ([]Token, error) {
+	if len(a.tokens) == 0 || a.tokens[0].Text != "{" {
+		return nil, errorAt(a.last, "expected { after %q", a.last.Text)
+	}
+
+	open := a.tokens[0]
+	depth := 0
+
+	for i, t := range a.tokens {
+		switch t.Text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+
+		if depth == 0 {
+			body := a.tokens[1:i]
+			a.tokens = a.tokens[i+1:]
+			return body, nil
+		}
+	}
+
+	return nil, errorAt(open, "{ is missing its closing }")
+}
+
+// emit writes the bytes at the current address.
+func (a *Assembler) emit(b ...byte) {
+	for _, v := range b {
+		i := int(a.here - a.Origin)
+		for len(a.out) <= i {
+			a.out = append(a.out, 0)
+		}
+
+		a.out[i] = v
+		a.here++
+	}
+}
+
+func (a *Assembler) emitOp(op uint16) {
+	a.emit(byte(op>>8), byte(op))
+}
+
+// jumpTarget returns an error at the token if the address does not fit in the 12 bits of NNN.
+func jumpTarget(t Token, addr uint16) error {
+	if addr > 0xFFF {
+		return errorAt(t, "address 0x%X does not fit in 12 bits", addr)
+	}
+
+	return nil
+}
+
+// emitJump emits a jump to the label, resolved once it is defined.
+func (a *Assembler) emitJump(t Token) {
+	a.fixups = append(a.fixups, fixup{Kind: fixupNNN, Address: a.here, Token: t})
+	a.emitOp(0x1000)
+}
+
+// patchJump points the jump at the address to the current address, the end of the
+// block opened at the token.
+func (a *Assembler) patchJump(addr uint16, t Token) error {
+	if err := jumpTarget(t, a.here); err != nil {
+		return err
+	}
+
+	i := int(addr - a.Origin)
+	a.out[i] = 0x10 | byte(a.here>>8)
+	a.out[i+1] = byte(a.here)
+
+	return nil
+}
+
+// value returns the numeric value of a literal, constant or defined label.
+func (a *Assembler) value(text string) (int, bool) {
+	if v, ok := a.consts[text]; ok {
+		return v, true
+	}
+
+	if v, ok := a.labels[text]; ok {
+		return int(v), true
+	}
+
+	base := 10
+	s := text
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	switch {
+	case strings.HasPrefix(s, "0x"):
+		base, s = 16, s[2:]
+	case strings.HasPrefix(s, "0b"):
+		base, s = 2, s[2:]
+	}
+
+	v, err := strconv.ParseInt(s, base, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	if neg {
+		v = -v
+	}
+
+	return int(v), true
+}
+
+// number reads the next token as a value within the bit size.
+func (a *Assembler) number(bits int) (int, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := a.value(t.Text)
+	if !ok {
+		return 0, errorAt(t, "expected a number, got %q", t.Text)
+	}
+
+	if err := fits(t, v, bits); err != nil {
+		return 0, err
+	}
+
+	return v & (1<<bits - 1), nil
+}
+
+// fits returns an error at the token if the value does not fit in the bits as either a
+// signed or an unsigned number.
+func fits(t Token, v, bits int) error {
+	if v < -(1<<(bits-1)) || v >= 1<<bits {
+		return errorAt(t, "value %d does not fit in %d bits", v, bits)
+	}
+
+	return nil
+}
+
+// register reads the next token as a register.
+func (a *Assembler) register() (byte, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+
+	r, ok := a.registerOf(t.Text)
+	if !ok {
+		return 0, errorAt(t, "expected a register, got %q", t.Text)
+	}
+
+	return r, nil
+}
+
+func (a *Assembler) registerOf(text string) (byte, bool) {
+	if r, ok := a.aliases[text]; ok {
+		return r, true
+	}
+
+	lower := strings.ToLower(text)
+	if len(lower) == 2 && lower[0] == 'v' {
+		if r, err := strconv.ParseUint(lower[1:], 16, 8); err == nil {
+			return byte(r), true
+		}
+	}
+
+	return 0, false
+}
+
+// address reads the next token as a 12-bit address for the instruction, adding a
+// fixup if it is a label which is not defined yet.
+func (a *Assembler) address(op uint16) error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if v, ok := a.value(t.Text); ok {
+		if v < 0 || v > 0xFFF {
+			return errorAt(t, "address 0x%X does not fit in 12 bits", v)
+		}
+
+		a.emitOp(op | uint16(v))
+		return nil
+	}
+
+	if !isName(t.Text) {
+		return errorAt(t, "expected an address, got %q", t.Text)
+	}
+
+	a.fixups = append(a.fixups, fixup{Kind: fixupNNN, Address: a.here, Token: t})
+	a.emitOp(op)
+
+	return nil
+}
+
+// isName returns true if the text can be a label name.
+func isName(text string) bool {
+	if text == "" || (text[0] >= '0' && text[0] <= '9') {
+		return false
+	}
+
+	for _, r := range text {
+		if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,83 @@
+package assembler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssemble(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		rom  []byte
+	}{
+		{"forward label", ": main jump later : later clear", []byte{0x12, 0x02, 0x00, 0xE0}},
+		{"backward label", ": main clear : top jump top", []byte{0x00, 0xE0, 0x12, 0x02}},
+		{"jump to main", ": sub return : main sub", []byte{0x12, 0x04, 0x00, 0xEE, 0x22, 0x02}},
+		{"index label", ": main i := data : data 0xF0", []byte{0xA2, 0x02, 0xF0}},
+		{"long index label", ": main i := long data : data 0xF0", []byte{0xF0, 0x00, 0x02, 0x04, 0xF0}},
+		{"const", ": main :const n 5 v0 := n", []byte{0x60, 0x05}},
+		{"alias", ": main :alias x v3 x := 1 v0 := x", []byte{0x63, 0x01, 0x80, 0x30}},
+		{"alias in condition", ": main :alias x v3 if v0 == x then clear", []byte{0x90, 0x30, 0x00, 0xE0}},
+		{"macro", ": main :macro inc r { r += 1 } inc v2", []byte{0x72, 0x01}},
+		{"macro operand", ": main :macro reg { v4 } v0 := reg", []byte{0x80, 0x40}},
+		{"macro in condition", ": main :macro reg { v4 } if v0 == reg then clear", []byte{0x90, 0x40, 0x00, 0xE0}},
+		{"macro range", ": main :macro to { - v3 } save v1 to", []byte{0x51, 0x32}},
+		{"calc", ": main :calc n { 3 + 4 } v0 := n", []byte{0x60, 0x07}},
+		{"calc right to left", ": main :calc n { 2 * 3 + 1 } v0 := n", []byte{0x60, 0x08}},
+		{"loop", ": main loop v0 += 1 again", []byte{0x70, 0x01, 0x12, 0x00}},
+		{"while", ": main loop while v0 != 5 v0 += 1 again", []byte{0x40, 0x05, 0x12, 0x08, 0x70, 0x01, 0x12, 0x00}},
+		{"if then", ": main if v0 == 1 then clear", []byte{0x40, 0x01, 0x00, 0xE0}},
+		{"if begin end", ": main if v0 == 1 begin clear end", []byte{0x30, 0x01, 0x12, 0x06, 0x00, 0xE0}},
+		{
+			"if begin else end",
+			": main if v0 == 1 begin clear else return end",
+			[]byte{0x30, 0x01, 0x12, 0x08, 0x00, 0xE0, 0x12, 0x0A, 0x00, 0xEE},
+		},
+		{"ordered compare", ": main if v1 < 3 then clear", []byte{0x6F, 0x03, 0x8F, 0x17, 0x4F, 0x00, 0x00, 0xE0}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			program, err := Assemble(c.src)
+			if assert.NoError(t, err) {
+				assert.Equal(t, c.rom, program.ROM)
+			}
+		})
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"value range", ": main\n  v0 := 300", "2:9: value 300 does not fit in 8 bits"},
+		{"undefined label", ": main jump nowhere", `1:13: undefined label "nowhere"`},
+		{"not a register", ": main load 5", `1:13: expected a register, got "5"`},
+		{"again without loop", "again", "1:1: again without loop"},
+		{"end without if", "end", "1:1: end without if ... begin"},
+		{"unclosed loop", ": main\nloop", "2:1: loop is missing its closing again"},
+		{"unclosed if", "if v0 == 1 begin", "1:1: if is missing its closing end"},
+		{"macro arguments", ":macro inc r { r += 1 } inc", "1:25: macro inc needs 1 arguments"},
+		{"label out of range", ": main jump far :org 0x1000 : far clear", "1:13: address 0x1000 does not fit in 12 bits"},
+		{"loop out of range", ":org 0x1000 loop again", "1:13: address 0x1000 does not fit in 12 bits"},
+		{"end out of range", ":org 0xFFE if v0 == 1 begin clear end", "1:35: address 0x1004 does not fit in 12 bits"},
+		{"division by zero", ":calc X { 3 / 0 }", "1:13: division by zero"},
+		{"modulo by zero", ":calc X { 3 % ( 1 - 1 ) }", "1:13: division by zero"},
+		{"byte range", ":byte 300", "1:7: value 300 does not fit in 8 bits"},
+		{"byte calc range", ":byte { 200 + 100 }", "1:9: value 300 does not fit in 8 bits"},
+		{"raw byte range", ": main -129", "1:8: value -129 does not fit in 8 bits"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Assemble(c.src)
+			if assert.Error(t, err) {
+				assert.Equal(t, c.err, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package assembler
+
+import "math"
+
+// binaryOps are the binary operators of :calc expressions.
+var binaryOps = map[string]func(a, b float64) float64{
+	"+":   func(a, b float64) float64 { return a + b },
+	"-":   func(a, b float64) float64 { return a - b },
+	"*":   func(a, b float64) float64 { return a * b },
+	"/":   func(a, b float64) float64 { return a / b },
+	"%":   func(a, b float64) float64 { return math.Mod(a, b) },
+	"&":   func(a, b float64) float64 { return float64(int64(a) & int64(b)) },
+	"|":   func(a, b float64) float64 { return float64(int64(a) | int64(b)) },
+	"^":   func(a, b float64) float64 { return float64(int64(a) ^ int64(b)) },
+	"<<":  func(a, b float64) float64 { return float64(int64(a) << int64(b)) },
+	">>":  func(a, b float64) float64 { return float64(int64(a) >> int64(b)) },
+	"<":   func(a, b float64) float64 { return boolValue(a < b) },
+	">":   func(a, b float64) float64 { return boolValue(a > b) },
+	"<=":  func(a, b float64) float64 { return boolValue(a <= b) },
+	">=":  func(a, b float64) float64 { return boolValue(a >= b) },
+	"==":  func(a, b float64) float64 { return boolValue(a == b) },
+	"!=":  func(a, b float64) float64 { return boolValue(a != b) },
+	"min": math.Min,
+	"max": math.Max,
+	"pow": math.Pow,
+}
+
+// unaryOps are the unary operators of :calc expressions.
+var unaryOps = map[string]func(a float64) float64{
+	"-":     func(a float64) float64 { return -a },
+	"~":     func(a float64) float64 { return float64(^int64(a)) },
+	"!":     func(a float64) float64 { return boolValue(a == 0) },
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"sign":  func(a float64) float64 { return float64(sign(a)) },
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func sign(a float64) int {
+	switch {
+	case a > 0:
+		return 1
+	case a < 0:
+		return -1
+	}
+
+	return 0
+}
+
+// calc evaluates a :calc expression. Like Octo, binary operators have no precedence
+// and are evaluated right to left, so parentheses must be used to group terms.
+func (a *Assembler) calc(tokens []Token) (float64, error) {
+	v, rest, err := a.calcExpr(tokens)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(rest) > 0 {
+		return 0, errorAt(rest[0], "unexpected %q in expression", rest[0].Text)
+	}
+
+	return v, nil
+}
+
+func (a *Assembler) calcExpr(tokens []Token) (float64, []Token, error) {
+	left, rest, err := a.calcTerm(tokens)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(rest) == 0 || rest[0].Text == ")" {
+		return left, rest, nil
+	}
+
+	t := rest[0]
+	op, ok := binaryOps[t.Text]
+	if !ok {
+		return 0, nil, errorAt(t, "unknown operator %q", t.Text)
+	}
+
+	right, rest, err := a.calcExpr(rest[1:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if (t.Text == "/" || t.Text == "%") && right == 0 {
+		return 0, nil, errorAt(t, "division by zero")
+	}
+
+	return op(left, right), rest, nil
+}
+
+func (a *Assembler) calcTerm(tokens []Token) (float64, []Token, error) {
+	if len(tokens) == 0 {
+		return 0, nil, errorAt(a.last, "unexpected end of expression")
+	}
+
+	t := tokens[0]
+
+	if t.Text == "(" {
+		v, rest, err := a.calcExpr(tokens[1:])
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if len(rest) == 0 || rest[0].Text != ")" {
+			return 0, nil, errorAt(t, "missing )")
+		}
+
+		return v, rest[1:], nil
+	}
+
+	if op, ok := unaryOps[t.Text]; ok {
+		v, rest, err := a.calcTerm(tokens[1:])
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return op(v), rest, nil
+	}
+
+	if t.Text == "HERE" {
+		return float64(a.here), tokens[1:], nil
+	}
+
+	if t.Text == "PI" {
+		return math.Pi, tokens[1:], nil
+	}
+
+	v, ok := a.value(t.Text)
+	if !ok {
+		return 0, nil, errorAt(t, "undefined name %q in expression", t.Text)
+	}
+
+	return float64(v), tokens[1:], nil
+}
@@ -0,0 +1,230 @@
+package assembler
+
+// condition is a compiled if or while condition.
+type condition struct {
+	// Prefix are the instructions computing a comparison into VF.
+	Prefix []uint16
+	// SkipIfTrue skips the next instruction when the condition holds.
+	SkipIfTrue uint16
+	// SkipIfFalse skips the next instruction when the condition does not hold.
+	SkipIfFalse uint16
+}
+
+// vfIsZero and vfIsNotZero are the conditions on the VF flag after a comparison prefix.
+var (
+	vfIsZero    = condition{SkipIfTrue: 0x3F00, SkipIfFalse: 0x4F00}
+	vfIsNotZero = condition{SkipIfTrue: 0x4F00, SkipIfFalse: 0x3F00}
+)
+
+// condition reads a condition: "vx key", "vx -key", or "vx <op> <vy or n>".
+func (a *Assembler) condition() (*condition, error) {
+	x, err := a.register()
+	if err != nil {
+		return nil, err
+	}
+
+	vx := uint16(x) << 8
+
+	op, err := a.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Text {
+	case "key":
+		return &condition{SkipIfTrue: 0xE09E | vx, SkipIfFalse: 0xE0A1 | vx}, nil
+	case "-key":
+		return &condition{SkipIfTrue: 0xE0A1 | vx, SkipIfFalse: 0xE09E | vx}, nil
+	}
+
+	rhs, ok, err := a.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errorAt(op, "expected a value after %q", op.Text)
+	}
+
+	y, isRegister := a.registerOf(rhs.Text)
+	n := 0
+	if isRegister {
+		a.next()
+	} else if n, err = a.number(8); err != nil {
+		return nil, err
+	}
+
+	vy := uint16(y) << 4
+
+	switch op.Text {
+	case "==", "!=":
+		c := &condition{SkipIfTrue: 0x3000 | vx | uint16(n), SkipIfFalse: 0x4000 | vx | uint16(n)}
+		if isRegister {
+			c = &condition{SkipIfTrue: 0x5000 | vx | vy, SkipIfFalse: 0x9000 | vx | vy}
+		}
+
+		if op.Text == "!=" {
+			c.SkipIfTrue, c.SkipIfFalse = c.SkipIfFalse, c.SkipIfTrue
+		}
+
+		return c, nil
+	}
+
+	// The ordered comparisons subtract into VF and test the borrow flag it is left holding.
+	// vf := a; vf -= b leaves VF = 1 if a >= b, and vf := b; vf =- a leaves VF = 1 if a >= b.
+	var prefix []uint16
+	var c condition
+
+	load := func(reg uint16) uint16 { return 0x8F00 | reg<<4 }
+	if isRegister {
+		switch op.Text {
+		case "<": // vx - vy borrows
+			prefix, c = []uint16{load(uint16(x)), 0x8F05 | vy}, vfIsZero
+		case ">": // vy - vx borrows
+			prefix, c = []uint16{load(uint16(y)), 0x8F05 | uint16(x)<<4}, vfIsZero
+		case "<=": // vy - vx does not borrow
+			prefix, c = []uint16{load(uint16(y)), 0x8F05 | uint16(x)<<4}, vfIsNotZero
+		case ">=": // vx - vy does not borrow
+			prefix, c = []uint16{load(uint16(x)), 0x8F05 | vy}, vfIsNotZero
+		}
+	} else {
+		imm := 0x6F00 | uint16(n)
+		switch op.Text {
+		case "<": // vx - n borrows
+			prefix, c = []uint16{imm, 0x8F07 | uint16(x)<<4}, vfIsZero
+		case ">": // n - vx borrows
+			prefix, c = []uint16{imm, 0x8F05 | uint16(x)<<4}, vfIsZero
+		case "<=": // n - vx does not borrow
+			prefix, c = []uint16{imm, 0x8F05 | uint16(x)<<4}, vfIsNotZero
+		case ">=": // vx - n does not borrow
+			prefix, c = []uint16{imm, 0x8F07 | uint16(x)<<4}, vfIsNotZero
+		}
+	}
+
+	if prefix == nil {
+		return nil, errorAt(op, "unknown comparison %q", op.Text)
+	}
+
+	c.Prefix = prefix
+
+	return &c, nil
+}
+
+// ifStatement assembles "if <cond> then <statement>" and "if <cond> begin".
+func (a *Assembler) ifStatement(t Token) error {
+	c, err := a.condition()
+	if err != nil {
+		return err
+	}
+
+	kind, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range c.Prefix {
+		a.emitOp(op)
+	}
+
+	switch kind.Text {
+	case "then":
+		a.emitOp(c.SkipIfFalse)
+		return a.statement()
+	case "begin":
+		// Skip the jump past the block when the condition holds.
+		a.emitOp(c.SkipIfTrue)
+		a.flows = append(a.flows, &flow{Kind: "if", Token: t, Jump: a.here})
+		a.emitOp(0x1000)
+		return nil
+	}
+
+	return errorAt(kind, "expected then or begin, got %q", kind.Text)
+}
+
+func (a *Assembler) elseStatement(t Token) error {
+	f := a.popFlow()
+	if f == nil || f.Kind != "if" {
+		return errorAt(t, "else without if ... begin")
+	}
+
+	jump := a.here
+	a.emitOp(0x1000)
+	if err := a.patchJump(f.Jump, t); err != nil {
+		return err
+	}
+
+	a.flows = append(a.flows, &flow{Kind: "else", Token: t, Jump: jump})
+
+	return nil
+}
+
+func (a *Assembler) endStatement(t Token) error {
+	f := a.popFlow()
+	if f == nil || (f.Kind != "if" && f.Kind != "else") {
+		return errorAt(t, "end without if ... begin")
+	}
+
+	return a.patchJump(f.Jump, t)
+}
+
+// whileStatement assembles "while <cond>", which leaves the innermost loop unless the condition holds.
+func (a *Assembler) whileStatement(t Token) error {
+	var loop *flow
+	for i := len(a.flows) - 1; i >= 0; i-- {
+		if a.flows[i].Kind == "loop" {
+			loop = a.flows[i]
+			break
+		}
+	}
+
+	if loop == nil {
+		return errorAt(t, "while outside of loop")
+	}
+
+	c, err := a.condition()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range c.Prefix {
+		a.emitOp(op)
+	}
+
+	a.emitOp(c.SkipIfTrue)
+	loop.Breaks = append(loop.Breaks, a.here)
+	a.emitOp(0x1000)
+
+	return nil
+}
+
+func (a *Assembler) againStatement(t Token) error {
+	f := a.popFlow()
+	if f == nil || f.Kind != "loop" {
+		return errorAt(t, "again without loop")
+	}
+
+	if err := jumpTarget(f.Token, f.Start); err != nil {
+		return err
+	}
+
+	a.emitOp(0x1000 | f.Start)
+
+	for _, addr := range f.Breaks {
+		if err := a.patchJump(addr, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *Assembler) popFlow() *flow {
+	if len(a.flows) == 0 {
+		return nil
+	}
+
+	f := a.flows[len(a.flows)-1]
+	a.flows = a.flows[:len(a.flows)-1]
+
+	return f
+}
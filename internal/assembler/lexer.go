@@ -0,0 +1,71 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Token is a single whitespace-separated token of the source with its position.
+type Token struct {
+	Text string
+	Line int
+	Col  int
+}
+
+// Error is an assembly error at a position in the source.
+type Error struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+// Error returns the error with its line and column.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// errorAt returns an Error at the position of the token.
+func errorAt(t Token, format string, args ...any) *Error {
+	return &Error{
+		Line: t.Line,
+		Col:  t.Col,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Tokenize splits the source into tokens, dropping # comments.
+func Tokenize(src string) []Token {
+	tokens := []Token{}
+
+	for i, line := range strings.Split(src, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+
+		col := 0
+		for col < len(line) {
+			for col < len(line) && isSpace(line[col]) {
+				col++
+			}
+
+			start := col
+			for col < len(line) && !isSpace(line[col]) {
+				col++
+			}
+
+			if col > start {
+				tokens = append(tokens, Token{
+					Text: line[start:col],
+					Line: i + 1,
+					Col:  start + 1,
+				})
+			}
+		}
+	}
+
+	return tokens
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r'
+}
@@ -0,0 +1,519 @@
+package assembler
+
+// simpleOps are the statements which take no operands.
+var simpleOps = map[string]uint16{
+	"clear":        0x00E0,
+	"return":       0x00EE,
+	";":            0x00EE,
+	"exit":         0x00FD,
+	"lores":        0x00FE,
+	"hires":        0x00FF,
+	"scroll-left":  0x00FC,
+	"scroll-right": 0x00FB,
+	"audio":        0xF002,
+}
+
+// registerOps are the statements which take a single register, placed in X.
+var registerOps = map[string]uint16{
+	"bcd":       0xF033,
+	"saveflags": 0xF075,
+	"loadflags": 0xF085,
+}
+
+// assignOps are the register to register operators, placed in N of 8XYN.
+var assignOps = map[string]uint16{
+	":=":  0x0,
+	"|=":  0x1,
+	"&=":  0x2,
+	"^=":  0x3,
+	"+=":  0x4,
+	"-=":  0x5,
+	">>=": 0x6,
+	"=-":  0x7,
+	"<<=": 0xE,
+}
+
+// timerTargets are the statements of the form "<target> := vx".
+var timerTargets = map[string]uint16{
+	"delay":  0xF015,
+	"buzzer": 0xF018,
+	"pitch":  0xF03A,
+}
+
+// statement assembles the next statement.
+func (a *Assembler) statement() error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if op, ok := simpleOps[t.Text]; ok {
+		a.emitOp(op)
+		return nil
+	}
+
+	if op, ok := registerOps[t.Text]; ok {
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+
+		a.emitOp(op | uint16(x)<<8)
+		return nil
+	}
+
+	if op, ok := timerTargets[t.Text]; ok {
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+
+		a.emitOp(op | uint16(x)<<8)
+		return nil
+	}
+
+	if _, ok := a.registerOf(t.Text); ok {
+		return a.registerStatement(t)
+	}
+
+	if _, ok := a.value(t.Text); ok {
+		if _, isLabel := a.labels[t.Text]; !isLabel {
+			v, _ := a.value(t.Text)
+			if err := fits(t, v, 8); err != nil {
+				return err
+			}
+
+			a.emit(byte(v))
+			return nil
+		}
+	}
+
+	switch t.Text {
+	case ":":
+		return a.label()
+	case ":const":
+		return a.constant()
+	case ":alias":
+		return a.alias()
+	case ":macro":
+		return a.macro()
+	case ":calc":
+		return a.calcStatement()
+	case ":byte":
+		return a.byteStatement()
+	case ":org":
+		v, err := a.number(16)
+		if err != nil {
+			return err
+		}
+		if uint16(v) < a.Origin {
+			return errorAt(t, ":org 0x%X is before the origin 0x%X", v, a.Origin)
+		}
+		a.here = uint16(v)
+	case ":call":
+		return a.address(0x2000)
+	case ":breakpoint":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		a.breakpoints[name.Text] = a.here
+	case "jump":
+		return a.address(0x1000)
+	case "jump0":
+		return a.address(0xB000)
+	case "native":
+		return a.address(0x0000)
+	case "scroll-down", "scroll-up":
+		n, err := a.number(4)
+		if err != nil {
+			return err
+		}
+		op := uint16(0x00C0)
+		if t.Text == "scroll-up" {
+			op = 0x00D0
+		}
+		a.emitOp(op | uint16(n))
+	case "plane":
+		n, err := a.number(4)
+		if err != nil {
+			return err
+		}
+		a.emitOp(0xF001 | uint16(n)<<8)
+	case "sprite":
+		return a.sprite()
+	case "load", "save":
+		return a.loadSave(t)
+	case "i":
+		return a.indexStatement()
+	case "if":
+		return a.ifStatement(t)
+	case "else":
+		return a.elseStatement(t)
+	case "end":
+		return a.endStatement(t)
+	case "loop":
+		a.flows = append(a.flows, &flow{Kind: "loop", Token: t, Start: a.here})
+	case "while":
+		return a.whileStatement(t)
+	case "again":
+		return a.againStatement(t)
+	default:
+		if !isName(t.Text) {
+			return errorAt(t, "unexpected %q", t.Text)
+		}
+
+		// A bare name calls the subroutine at that label.
+		a.tokens = append([]Token{t}, a.tokens...)
+		return a.address(0x2000)
+	}
+
+	return nil
+}
+
+func (a *Assembler) label() error {
+	name, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if !isName(name.Text) {
+		return errorAt(name, "invalid label name %q", name.Text)
+	}
+
+	if _, ok := a.labels[name.Text]; ok {
+		return errorAt(name, "label %q is already defined", name.Text)
+	}
+
+	a.labels[name.Text] = a.here
+
+	return nil
+}
+
+func (a *Assembler) constant() error {
+	name, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	v, err := a.number(16)
+	if err != nil {
+		return err
+	}
+
+	a.consts[name.Text] = v
+
+	return nil
+}
+
+func (a *Assembler) alias() error {
+	name, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	r, err := a.register()
+	if err != nil {
+		return err
+	}
+
+	a.aliases[name.Text] = r
+
+	return nil
+}
+
+func (a *Assembler) macro() error {
+	name, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	params := []string{}
+	for len(a.tokens) > 0 && a.tokens[0].Text != "{" {
+		p, _ := a.next()
+		params = append(params, p.Text)
+	}
+
+	body, err := a.block()
+	if err != nil {
+		return err
+	}
+
+	a.macros[name.Text] = &macro{Params: params, Body: body}
+
+	return nil
+}
+
+func (a *Assembler) calcStatement() error {
+	name, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	body, err := a.block()
+	if err != nil {
+		return err
+	}
+
+	v, err := a.calc(body)
+	if err != nil {
+		return err
+	}
+
+	a.consts[name.Text] = int(v)
+
+	return nil
+}
+
+func (a *Assembler) byteStatement() error {
+	if len(a.tokens) > 0 && a.tokens[0].Text == "{" {
+		body, err := a.block()
+		if err != nil {
+			return err
+		}
+
+		v, err := a.calc(body)
+		if err != nil {
+			return err
+		}
+
+		if err := fits(body[0], int(v), 8); err != nil {
+			return err
+		}
+
+		a.emit(byte(int(v)))
+		return nil
+	}
+
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if v, ok := a.value(t.Text); ok {
+		if err := fits(t, v, 8); err != nil {
+			return err
+		}
+
+		a.emit(byte(v))
+		return nil
+	}
+
+	if !isName(t.Text) {
+		return errorAt(t, "expected a byte, got %q", t.Text)
+	}
+
+	a.fixups = append(a.fixups, fixup{Kind: fixupByte, Address: a.here, Token: t})
+	a.emit(0)
+
+	return nil
+}
+
+func (a *Assembler) sprite() error {
+	x, err := a.register()
+	if err != nil {
+		return err
+	}
+
+	y, err := a.register()
+	if err != nil {
+		return err
+	}
+
+	n, err := a.number(4)
+	if err != nil {
+		return err
+	}
+
+	a.emitOp(0xD000 | uint16(x)<<8 | uint16(y)<<4 | uint16(n))
+
+	return nil
+}
+
+// loadSave assembles "load vx", "save vx" and the XO-CHIP ranges "load vx - vy".
+func (a *Assembler) loadSave(t Token) error {
+	x, err := a.register()
+	if err != nil {
+		return err
+	}
+
+	next, ok, err := a.peek()
+	if err != nil {
+		return err
+	}
+
+	if ok && next.Text == "-" {
+		a.next()
+
+		y, err := a.register()
+		if err != nil {
+			return err
+		}
+
+		op := uint16(0x5002)
+		if t.Text == "load" {
+			op = 0x5003
+		}
+
+		a.emitOp(op | uint16(x)<<8 | uint16(y)<<4)
+		return nil
+	}
+
+	op := uint16(0xF055)
+	if t.Text == "load" {
+		op = 0xF065
+	}
+
+	a.emitOp(op | uint16(x)<<8)
+
+	return nil
+}
+
+// indexStatement assembles the statements which start with i.
+func (a *Assembler) indexStatement() error {
+	op, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if op.Text == "+=" {
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+
+		a.emitOp(0xF01E | uint16(x)<<8)
+		return nil
+	}
+
+	if op.Text != ":=" {
+		return errorAt(op, "expected := or += after i, got %q", op.Text)
+	}
+
+	rhs, ok, err := a.peek()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errorAt(op, "expected a value after i :=")
+	}
+
+	switch rhs.Text {
+	case "hex", "bighex":
+		kind, _ := a.next()
+
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+
+		code := uint16(0xF029)
+		if kind.Text == "bighex" {
+			code = 0xF030
+		}
+
+		a.emitOp(code | uint16(x)<<8)
+		return nil
+	case "long":
+		a.next()
+		a.emitOp(0xF000)
+
+		t, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		if v, ok := a.value(t.Text); ok {
+			a.emitOp(uint16(v))
+			return nil
+		}
+
+		if !isName(t.Text) {
+			return errorAt(t, "expected an address, got %q", t.Text)
+		}
+
+		a.fixups = append(a.fixups, fixup{Kind: fixupWord, Address: a.here, Token: t})
+		a.emitOp(0)
+		return nil
+	}
+
+	return a.address(0xA000)
+}
+
+// registerStatement assembles the statements which start with a register.
+func (a *Assembler) registerStatement(t Token) error {
+	x, _ := a.registerOf(t.Text)
+	vx := uint16(x) << 8
+
+	op, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	rhs, ok, err := a.peek()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errorAt(op, "expected a value after %q", op.Text)
+	}
+
+	if op.Text == ":=" {
+		switch rhs.Text {
+		case "random":
+			a.next()
+			n, err := a.number(8)
+			if err != nil {
+				return err
+			}
+			a.emitOp(0xC000 | vx | uint16(n))
+			return nil
+		case "key":
+			a.next()
+			a.emitOp(0xF00A | vx)
+			return nil
+		case "delay":
+			a.next()
+			a.emitOp(0xF007 | vx)
+			return nil
+		}
+	}
+
+	if y, ok := a.registerOf(rhs.Text); ok {
+		code, ok := assignOps[op.Text]
+		if !ok {
+			return errorAt(op, "unknown operator %q", op.Text)
+		}
+
+		a.next()
+		a.emitOp(0x8000 | vx | uint16(y)<<4 | code)
+		return nil
+	}
+
+	switch op.Text {
+	case ":=", "+=", "-=":
+		n, err := a.number(8)
+		if err != nil {
+			return err
+		}
+
+		switch op.Text {
+		case ":=":
+			a.emitOp(0x6000 | vx | uint16(n))
+		case "+=":
+			a.emitOp(0x7000 | vx | uint16(n))
+		case "-=":
+			a.emitOp(0x7000 | vx | uint16(-n)&0xFF)
+		}
+
+		return nil
+	}
+
+	return errorAt(op, "operator %q needs a register on the right", op.Text)
+}
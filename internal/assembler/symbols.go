@@ -0,0 +1,74 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a named address.
+type Symbol struct {
+	Name    string
+	Address uint16
+}
+
+// Symbols are the labels and breakpoints of an assembled program.
+// The symbol file has one "label <name> <addr>" or "breakpoint <name> <addr>" per line.
+type Symbols struct {
+	Labels      []Symbol
+	Breakpoints []Symbol
+}
+
+// Write writes the symbol file.
+func (s *Symbols) Write(w io.Writer) error {
+	for _, l := range s.Labels {
+		if _, err := fmt.Fprintf(w, "label %s 0x%04X\n", l.Name, l.Address); err != nil {
+			return err
+		}
+	}
+
+	for _, b := range s.Breakpoints {
+		if _, err := fmt.Fprintf(w, "breakpoint %s 0x%04X\n", b.Name, b.Address); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadSymbols reads a symbol file.
+func ReadSymbols(r io.Reader) (*Symbols, error) {
+	s := &Symbols{}
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("symbols line %d: expected <kind> <name> <addr>", line)
+		}
+
+		addr, err := strconv.ParseUint(fields[2], 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("symbols line %d: invalid address %q", line, fields[2])
+		}
+
+		sym := Symbol{Name: fields[1], Address: uint16(addr)}
+
+		switch fields[0] {
+		case "label":
+			s.Labels = append(s.Labels, sym)
+		case "breakpoint":
+			s.Breakpoints = append(s.Breakpoints, sym)
+		default:
+			return nil, fmt.Errorf("symbols line %d: unknown kind %q", line, fields[0])
+		}
+	}
+
+	return s, scanner.Err()
+}
@@ -0,0 +1,114 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jamrig/chippy/internal/emulator"
+)
+
+// Condition is a comparison of a register against a value, such as "V3 == 0x10".
+type Condition struct {
+	// Register is the register name: V0-VF, I, PC, SP, DT or ST.
+	Register string
+	// Op is the comparison operator.
+	Op string
+	// Value is the value compared against.
+	Value int
+}
+
+// conditionOps are the supported comparison operators, longest first for parsing.
+var conditionOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// ParseCondition parses a condition of the form "<register> <op> <value>".
+func ParseCondition(s string) (*Condition, error) {
+	for _, op := range conditionOps {
+		reg, val, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+
+		c := &Condition{
+			Register: strings.ToUpper(strings.TrimSpace(reg)),
+			Op:       op,
+		}
+
+		if _, err := c.read(&emulator.CPU{}); err != nil {
+			return nil, err
+		}
+
+		v, err := strconv.ParseInt(strings.TrimSpace(val), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", strings.TrimSpace(val))
+		}
+
+		c.Value = int(v)
+
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("condition %q must be of the form <register> <op> <value>", s)
+}
+
+// Eval returns true if the condition holds for the CPU.
+func (c *Condition) Eval(cpu *emulator.CPU) bool {
+	v, err := c.read(cpu)
+	if err != nil {
+		return false
+	}
+
+	switch c.Op {
+	case "==":
+		return v == c.Value
+	case "!=":
+		return v != c.Value
+	case "<":
+		return v < c.Value
+	case ">":
+		return v > c.Value
+	case "<=":
+		return v <= c.Value
+	case ">=":
+		return v >= c.Value
+	}
+
+	return false
+}
+
+// String returns the condition in its parsed form.
+func (c *Condition) String() string {
+	return fmt.Sprintf("%s %s 0x%X", c.Register, c.Op, c.Value)
+}
+
+func (c *Condition) read(cpu *emulator.CPU) (int, error) {
+	switch c.Register {
+	case "I":
+		return int(cpu.I), nil
+	case "PC":
+		return int(cpu.PC), nil
+	case "SP":
+		if cpu.Stack == nil {
+			return 0, nil
+		}
+		return cpu.Stack.Count, nil
+	case "DT":
+		if cpu.DelayTimer == nil {
+			return 0, nil
+		}
+		return cpu.DelayTimer.GetValue(), nil
+	case "ST":
+		if cpu.SoundTimer == nil {
+			return 0, nil
+		}
+		return cpu.SoundTimer.GetValue(), nil
+	}
+
+	if len(c.Register) == 2 && c.Register[0] == 'V' {
+		if idx, err := strconv.ParseUint(c.Register[1:], 16, 8); err == nil {
+			return int(cpu.V[idx]), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown register %q", c.Register)
+}
@@ -0,0 +1,189 @@
+package debugger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jamrig/chippy/internal/assembler"
+	"github.com/jamrig/chippy/internal/disasm"
+	"github.com/jamrig/chippy/internal/emulator"
+)
+
+// WatchKind is the kind of memory access a watchpoint stops at.
+type WatchKind int
+
+const (
+	// WatchRead stops when the address is read.
+	WatchRead WatchKind = 1 << iota
+	// WatchWrite stops when the address is written.
+	WatchWrite
+	// WatchReadWrite stops when the address is read or written.
+	WatchReadWrite = WatchRead | WatchWrite
+)
+
+// String returns the name of the watch kind.
+func (k WatchKind) String() string {
+	switch k {
+	case WatchRead:
+		return "r"
+	case WatchWrite:
+		return "w"
+	case WatchReadWrite:
+		return "rw"
+	}
+
+	return "unknown"
+}
+
+// Debugger controls the execution of an Emulator one instruction at a time.
+type Debugger struct {
+	Emulator *emulator.Emulator
+	// Breakpoints are the PC addresses to stop at.
+	Breakpoints map[uint16]bool
+	// Watchpoints are the memory addresses to stop at when accessed.
+	Watchpoints map[uint16]WatchKind
+	// Conditions are the register conditions to stop at once they are true.
+	Conditions []*Condition
+	// Reason is the reason execution last stopped, empty if it did not stop early.
+	Reason string
+	// Period is the time in nanoseconds the timers advance for each instruction.
+	Period int64
+	// Labels are the names of addresses shown in the disassembly.
+	Labels map[uint16]string
+}
+
+// New returns a new Debugger for the emulator, hooking its memory for watchpoints.
+func New(e *emulator.Emulator) *Debugger {
+	d := &Debugger{
+		Emulator:    e,
+		Breakpoints: map[uint16]bool{},
+		Watchpoints: map[uint16]WatchKind{},
+		Conditions:  []*Condition{},
+		Reason:      "",
+		Period:      int64(time.Second / time.Duration(e.Config.CPU.InstructionTimerFrequency)),
+		Labels:      disasm.New(e.Config).Disassemble(e.Program).Labels,
+	}
+
+	e.Memory.OnRead = func(addr uint16) {
+		if d.Watchpoints[addr]&WatchRead != 0 {
+			d.Reason = fmt.Sprintf("watchpoint: read 0x%04X", addr)
+		}
+	}
+
+	e.Memory.OnWrite = func(addr uint16, val byte) {
+		if d.Watchpoints[addr]&WatchWrite != 0 {
+			d.Reason = fmt.Sprintf("watchpoint: write 0x%02X to 0x%04X", val, addr)
+		}
+	}
+
+	return d
+}
+
+// Step executes a single instruction and advances the timers by one instruction period.
+func (d *Debugger) Step() {
+	e := d.Emulator
+
+	d.Reason = ""
+
+	if err := e.CPU.Step(); err != nil {
+		d.Reason = err.Error()
+		return
+	}
+
+	e.CPU.DelayTimer.Tick(d.Period)
+	e.CPU.SoundTimer.Tick(d.Period)
+	e.Display.Advance(d.Period)
+
+	if e.CPU.Exited {
+		d.Reason = "program exited"
+	}
+}
+
+// Continue executes until a breakpoint, watchpoint or condition is hit, or until the
+// interrupt channel receives.
+func (d *Debugger) Continue(interrupt <-chan struct{}) {
+	d.runUntil(func() bool { return false }, interrupt)
+}
+
+// StepOver executes the next instruction, running a 2NNN call through to its return.
+func (d *Debugger) StepOver(interrupt <-chan struct{}) {
+	cpu := d.Emulator.CPU
+
+	op := d.opcodeAt(cpu.PC)
+	if op.F != 2 {
+		d.Step()
+		return
+	}
+
+	ret := cpu.PC + 2
+	depth := cpu.Stack.Count
+
+	d.runUntil(func() bool { return cpu.PC == ret && cpu.Stack.Count == depth }, interrupt)
+}
+
+// StepOut executes until the current subroutine returns with 00EE.
+func (d *Debugger) StepOut(interrupt <-chan struct{}) error {
+	cpu := d.Emulator.CPU
+
+	depth := cpu.Stack.Count
+	if depth == 0 {
+		return fmt.Errorf("not in a subroutine")
+	}
+
+	d.runUntil(func() bool { return cpu.Stack.Count < depth }, interrupt)
+
+	return nil
+}
+
+// runUntil steps until done returns true or execution stops for another reason.
+func (d *Debugger) runUntil(done func() bool, interrupt <-chan struct{}) {
+	cpu := d.Emulator.CPU
+
+	for i := 0; ; i++ {
+		d.Step()
+
+		if d.Reason != "" || done() {
+			return
+		}
+
+		if d.Breakpoints[cpu.PC] {
+			d.Reason = fmt.Sprintf("breakpoint: 0x%04X", cpu.PC)
+			return
+		}
+
+		for _, cond := range d.Conditions {
+			if cond.Eval(cpu) {
+				d.Reason = fmt.Sprintf("condition: %s", cond)
+				return
+			}
+		}
+
+		// Only check for an interrupt every so often to keep stepping fast.
+		if i%1024 == 0 {
+			select {
+			case <-interrupt:
+				d.Reason = "interrupted"
+				return
+			default:
+			}
+		}
+	}
+}
+
+// opcodeAt returns the opcode at the address without triggering watchpoints.
+func (d *Debugger) opcodeAt(addr uint16) emulator.Opcode {
+	m := d.Emulator.Memory
+
+	return emulator.NewOpcode(uint16(m.Peek(addr))<<0x08 + uint16(m.Peek(addr+1)))
+}
+
+// AddSymbols adds the labels and breakpoints from an assembler symbol file.
+func (d *Debugger) AddSymbols(symbols *assembler.Symbols) {
+	for _, l := range symbols.Labels {
+		d.Labels[l.Address] = l.Name
+	}
+
+	for _, b := range symbols.Breakpoints {
+		d.Breakpoints[b.Address] = true
+	}
+}
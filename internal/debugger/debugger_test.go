@@ -0,0 +1,214 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamrig/chippy/internal/assembler"
+	"github.com/jamrig/chippy/internal/emulator"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSource calls a subroutine, saves V0 to data and then counts up V2 forever.
+//
+//	0200 i := data   0202 v0 := 1   0204 sub   0206 v1 := 2   0208 save v0
+//	020A v2 += 1     020C jump      020E v3 := 7   0210 return   0212 data
+const testSource = `
+: main
+  i := data
+  v0 := 1
+  sub
+  v1 := 2
+  save v0
+  loop
+    v2 += 1
+  again
+: sub
+  v3 := 7
+  return
+: data 0`
+
+// newTestDebugger returns a debugger for an emulator running the assembled source.
+func newTestDebugger(t *testing.T) *Debugger {
+	t.Helper()
+
+	program, err := assembler.Assemble(testSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := emulator.NewFromProgram(emulator.CHIP8Config.Clone(), program.ROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(e)
+}
+
+// timeout returns an interrupt channel which stops a run that never hits its stop.
+func timeout(t *testing.T) <-chan struct{} {
+	interrupt := make(chan struct{})
+	timer := time.AfterFunc(time.Second, func() { close(interrupt) })
+	t.Cleanup(func() { timer.Stop() })
+
+	return interrupt
+}
+
+func TestStep(t *testing.T) {
+	d := newTestDebugger(t)
+
+	d.Step()
+	d.Step()
+
+	assert.Equal(t, uint16(0x204), d.Emulator.CPU.PC)
+	assert.Equal(t, byte(1), d.Emulator.CPU.V[0])
+	assert.Empty(t, d.Reason)
+}
+
+func TestBreakpoint(t *testing.T) {
+	d := newTestDebugger(t)
+	d.Breakpoints[0x208] = true
+
+	d.Continue(timeout(t))
+
+	assert.Equal(t, "breakpoint: 0x0208", d.Reason)
+	assert.Equal(t, uint16(0x208), d.Emulator.CPU.PC)
+}
+
+func TestWatchpoints(t *testing.T) {
+	cases := []struct {
+		name   string
+		kind   WatchKind
+		reason string
+	}{
+		{"write", WatchWrite, "watchpoint: write 0x01 to 0x0212"},
+		{"read write", WatchReadWrite, "watchpoint: write 0x01 to 0x0212"},
+		{"read", WatchRead, "interrupted"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := newTestDebugger(t)
+			d.Watchpoints[0x212] = c.kind
+
+			d.Continue(timeout(t))
+
+			assert.Equal(t, c.reason, d.Reason)
+		})
+	}
+}
+
+func TestCondition(t *testing.T) {
+	d := newTestDebugger(t)
+
+	cond, err := ParseCondition("v2 >= 3")
+	if !assert.NoError(t, err) {
+		return
+	}
+	d.Conditions = append(d.Conditions, cond)
+
+	d.Continue(timeout(t))
+
+	assert.Equal(t, "condition: V2 >= 0x3", d.Reason)
+	assert.Equal(t, byte(3), d.Emulator.CPU.V[2])
+	assert.Equal(t, uint16(0x20C), d.Emulator.CPU.PC)
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	cases := []string{"V3", "VG == 1", "Q < 2", "V3 == x"}
+
+	for _, c := range cases {
+		_, err := ParseCondition(c)
+		assert.Error(t, err, c)
+	}
+}
+
+func TestStepOver(t *testing.T) {
+	d := newTestDebugger(t)
+	d.Step()
+	d.Step()
+
+	d.StepOver(timeout(t))
+
+	assert.Empty(t, d.Reason)
+	assert.Equal(t, uint16(0x206), d.Emulator.CPU.PC)
+	assert.Equal(t, byte(7), d.Emulator.CPU.V[3], "subroutine ran")
+	assert.Equal(t, 0, d.Emulator.CPU.Stack.Count)
+}
+
+func TestStepOut(t *testing.T) {
+	d := newTestDebugger(t)
+
+	assert.Error(t, d.StepOut(timeout(t)), "not in a subroutine")
+
+	d.Step()
+	d.Step()
+	d.Step()
+	assert.Equal(t, uint16(0x20E), d.Emulator.CPU.PC)
+
+	assert.NoError(t, d.StepOut(timeout(t)))
+	assert.Equal(t, uint16(0x206), d.Emulator.CPU.PC)
+	assert.Equal(t, 0, d.Emulator.CPU.Stack.Count)
+}
+
+func TestPrompt(t *testing.T) {
+	d := newTestDebugger(t)
+
+	in := strings.Join([]string{
+		"break 0x206",
+		"watch 0x212 w",
+		"cond V2 == 2",
+		"list",
+		"continue",
+		"next",
+		"delete 0x206",
+		"uncond 0",
+		"key a",
+		"key 0xB",
+		"key g",
+		"step 2",
+		"bogus",
+		"quit",
+		"step",
+	}, "\n")
+
+	out := &strings.Builder{}
+	assert.NoError(t, d.Run(strings.NewReader(in), out))
+
+	for _, want := range []string{
+		"break  0x0206\n",
+		"watch  0x0212 w\n",
+		"cond 0 V2 == 0x2\n",
+		"stopped: breakpoint: 0x0206\n",
+		"stopped: watchpoint: write 0x01 to 0x0212\n",
+		"error: invalid key \"g\", must be 0-F\n",
+		"error: unknown command \"bogus\", try help\n",
+	} {
+		assert.Contains(t, out.String(), want)
+	}
+
+	assert.True(t, d.Emulator.Keypad.IsPressed(0xA))
+	assert.True(t, d.Emulator.Keypad.IsPressed(0xB))
+	assert.Empty(t, d.Breakpoints)
+	assert.Empty(t, d.Conditions)
+	assert.Equal(t, uint16(0x20A), d.Emulator.CPU.PC, "step stops at the watchpoint and quit stops reading")
+}
+
+func TestPromptHexAddresses(t *testing.T) {
+	d := newTestDebugger(t)
+
+	in := "b 200\nb 20A\nw 0x212 w\nx 212 1\ncontinue\n"
+	out := &strings.Builder{}
+	assert.NoError(t, d.Run(strings.NewReader(in), out))
+
+	assert.Equal(t, map[uint16]bool{0x200: true, 0x20A: true}, d.Breakpoints)
+	assert.Equal(t, WatchWrite, d.Watchpoints[0x212])
+	assert.Contains(t, out.String(), "stopped: watchpoint: write 0x01 to 0x0212\n")
+	assert.NotContains(t, out.String(), "error:")
+
+	out.Reset()
+	assert.NoError(t, d.Run(strings.NewReader("continue\nb 10000\n"), out))
+	assert.Contains(t, out.String(), "stopped: breakpoint: 0x020A\n")
+	assert.Contains(t, out.String(), "error: invalid address \"10000\", must be hex\n")
+}
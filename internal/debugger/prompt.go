@@ -0,0 +1,411 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jamrig/chippy/internal/disasm"
+	"github.com/jamrig/chippy/internal/emulator"
+)
+
+// promptHelp is the help text for the prompt commands.
+const promptHelp = `commands:
+  s, step [n]          execute n instructions (default 1)
+  n, next              step over a 2NNN call
+  o, out               step out of the current subroutine
+  c, continue          run until a breakpoint, watchpoint or condition (Ctrl-C to stop)
+  b, break <addr>      add a PC breakpoint
+  w, watch <addr> [r|w|rw]
+                       add a memory watchpoint (default rw)
+  cond <expr>          add a register condition, e.g. "V3 == 0x10" or "I >= 0x300"
+  d, delete <addr>     delete the breakpoint or watchpoint at addr
+  uncond <n>           delete condition n
+  l, list              list the breakpoints, watchpoints and conditions
+  r, regs              show the registers, stack and timers
+  dis [addr] [n]       disassemble n instructions around addr (default PC)
+  x <addr> [n]         dump n bytes of memory (default 16)
+  screen               show the display buffer
+  key <k> [up]         press (or release) keypad key k
+  q, quit              exit the debugger
+
+addresses are hex, with or without 0x`
+
+// Run reads commands from in and writes the results to out until quit or end of input.
+func (d *Debugger) Run(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+
+	d.Emulator.CPU.OnFault = func(f *emulator.Fault) {
+		fmt.Fprintf(out, "fault: %s\n", f)
+	}
+
+	d.showState(out)
+
+	for {
+		fmt.Fprint(out, "(chippy) ")
+
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		quit, err := d.exec(out, fields[0], fields[1:])
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+
+		if quit {
+			return nil
+		}
+	}
+}
+
+func (d *Debugger) exec(out io.Writer, cmd string, args []string) (bool, error) {
+	switch cmd {
+	case "h", "help":
+		fmt.Fprintln(out, promptHelp)
+	case "s", "step":
+		n := 1
+		if len(args) > 0 {
+			v, err := strconv.Atoi(args[0])
+			if err != nil {
+				return false, err
+			}
+			n = v
+		}
+
+		for i := 0; i < n; i++ {
+			d.Step()
+			if d.Reason != "" {
+				break
+			}
+		}
+
+		d.showState(out)
+	case "n", "next":
+		d.withInterrupt(d.StepOver)
+		d.showState(out)
+	case "o", "out":
+		var err error
+		d.withInterrupt(func(interrupt <-chan struct{}) { err = d.StepOut(interrupt) })
+		if err != nil {
+			return false, err
+		}
+		d.showState(out)
+	case "c", "continue":
+		d.withInterrupt(d.Continue)
+		d.showState(out)
+	case "b", "break":
+		addr, err := parseAddr(args, 0)
+		if err != nil {
+			return false, err
+		}
+		d.Breakpoints[addr] = true
+	case "w", "watch":
+		addr, err := parseAddr(args, 0)
+		if err != nil {
+			return false, err
+		}
+
+		kind := WatchReadWrite
+		if len(args) > 1 {
+			switch args[1] {
+			case "r":
+				kind = WatchRead
+			case "w":
+				kind = WatchWrite
+			case "rw":
+			default:
+				return false, fmt.Errorf("watch kind must be r, w or rw")
+			}
+		}
+		d.Watchpoints[addr] = kind
+	case "cond":
+		cond, err := ParseCondition(strings.Join(args, " "))
+		if err != nil {
+			return false, err
+		}
+		d.Conditions = append(d.Conditions, cond)
+	case "d", "delete":
+		return false, d.delete(args)
+	case "uncond":
+		n, err := parseArg(args, 0)
+		if err != nil {
+			return false, err
+		}
+
+		if n < 0 || n >= len(d.Conditions) {
+			return false, fmt.Errorf("no condition %d", n)
+		}
+		d.Conditions = append(d.Conditions[:n], d.Conditions[n+1:]...)
+	case "l", "list":
+		d.showList(out)
+	case "r", "regs":
+		d.showRegisters(out)
+	case "dis":
+		addr := d.Emulator.CPU.PC
+		if len(args) > 0 {
+			v, err := parseAddr(args, 0)
+			if err != nil {
+				return false, err
+			}
+			addr = v
+		}
+
+		n := 10
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil {
+				return false, err
+			}
+			n = v
+		}
+
+		d.showDisassembly(out, addr, n)
+	case "x":
+		addr, err := parseAddr(args, 0)
+		if err != nil {
+			return false, err
+		}
+
+		n := 16
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil {
+				return false, err
+			}
+		}
+
+		d.showMemory(out, addr, n)
+	case "screen":
+		d.showScreen(out)
+	case "key":
+		key, err := parseKey(args, 0)
+		if err != nil {
+			return false, err
+		}
+
+		if len(args) > 1 && args[1] == "up" {
+			d.Emulator.Keypad.Release(key)
+		} else {
+			d.Emulator.Keypad.Press(key)
+		}
+	case "q", "quit":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %q, try help", cmd)
+	}
+
+	return false, nil
+}
+
+func (d *Debugger) delete(args []string) error {
+	addr, err := parseAddr(args, 0)
+	if err != nil {
+		return err
+	}
+
+	if d.Breakpoints[addr] {
+		delete(d.Breakpoints, addr)
+		return nil
+	}
+
+	if _, ok := d.Watchpoints[addr]; ok {
+		delete(d.Watchpoints, addr)
+		return nil
+	}
+
+	return fmt.Errorf("nothing to delete at %s", args[0])
+}
+
+// withInterrupt runs the function with a channel which receives on Ctrl-C.
+func (d *Debugger) withInterrupt(run func(interrupt <-chan struct{})) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	interrupt := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-signals:
+			close(interrupt)
+		case <-done:
+		}
+	}()
+
+	run(interrupt)
+}
+
+func (d *Debugger) showState(out io.Writer) {
+	if d.Reason != "" {
+		fmt.Fprintf(out, "stopped: %s\n", d.Reason)
+	}
+
+	d.showRegisters(out)
+	d.showDisassembly(out, d.Emulator.CPU.PC, 10)
+}
+
+func (d *Debugger) showRegisters(out io.Writer) {
+	cpu := d.Emulator.CPU
+
+	for i := 0; i < 16; i++ {
+		fmt.Fprintf(out, "V%X=%02X ", i, cpu.V[i])
+		if i == 7 {
+			fmt.Fprintln(out)
+		}
+	}
+	fmt.Fprintln(out)
+
+	fmt.Fprintf(out, "PC=%04X I=%04X DT=%02X ST=%02X\n", cpu.PC, cpu.I, cpu.DelayTimer.GetValue(), cpu.SoundTimer.GetValue())
+
+	fmt.Fprint(out, "stack:")
+	for i := 0; i < cpu.Stack.Count; i++ {
+		fmt.Fprintf(out, " %04X", cpu.Stack.Data[i])
+	}
+	fmt.Fprintln(out)
+}
+
+// showDisassembly shows n instructions with the address in the middle.
+func (d *Debugger) showDisassembly(out io.Writer, addr uint16, n int) {
+	start := int(addr) - (n/2)*2
+	if start < 0 {
+		start = 0
+	}
+
+	for i := 0; i < n; i++ {
+		a := uint16(start + i*2)
+		op := d.opcodeAt(a)
+
+		if label, ok := d.Labels[a]; ok {
+			fmt.Fprintf(out, "%s:\n", label)
+		}
+
+		marker := "  "
+		if a == d.Emulator.CPU.PC {
+			marker = "=>"
+		}
+
+		bp := " "
+		if d.Breakpoints[a] {
+			bp = "*"
+		}
+
+		name := "???"
+		if instr := d.Emulator.CPU.Dispatch[op.Raw]; instr != nil {
+			name = disasm.Mnemonic(instr, op.Raw, d.opcodeAt(a+2).Raw, d.Labels)
+		}
+
+		fmt.Fprintf(out, "%s%s %04X  %04X  %s\n", marker, bp, a, op.Raw, name)
+	}
+}
+
+func (d *Debugger) showMemory(out io.Writer, addr uint16, n int) {
+	m := d.Emulator.Memory
+
+	for i := 0; i < n; i += 16 {
+		fmt.Fprintf(out, "%04X:", int(addr)+i)
+		for j := i; j < i+16 && j < n; j++ {
+			fmt.Fprintf(out, " %02X", m.Peek(addr+uint16(j)))
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func (d *Debugger) showScreen(out io.Writer) {
+	disp := d.Emulator.Display
+
+	sb := &strings.Builder{}
+	for y := 0; y < disp.Height; y++ {
+		for x := 0; x < disp.Width; x++ {
+			if disp.Buffer[y*disp.Width+x] != 0 {
+				sb.WriteByte('#')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+
+	fmt.Fprint(out, sb.String())
+}
+
+func (d *Debugger) showList(out io.Writer) {
+	addrs := []int{}
+	for addr := range d.Breakpoints {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+
+	for _, addr := range addrs {
+		fmt.Fprintf(out, "break  0x%04X\n", addr)
+	}
+
+	addrs = addrs[:0]
+	for addr := range d.Watchpoints {
+		addrs = append(addrs, int(addr))
+	}
+	sort.Ints(addrs)
+
+	for _, addr := range addrs {
+		fmt.Fprintf(out, "watch  0x%04X %s\n", addr, d.Watchpoints[uint16(addr)])
+	}
+
+	for i, cond := range d.Conditions {
+		fmt.Fprintf(out, "cond %d %s\n", i, cond)
+	}
+}
+
+// parseArg parses the argument at the index as a number, allowing 0x prefixes.
+func parseArg(args []string, idx int) (int, error) {
+	if idx >= len(args) {
+		return 0, fmt.Errorf("missing argument")
+	}
+
+	v, err := strconv.ParseInt(args[idx], 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", args[idx])
+	}
+
+	return int(v), nil
+}
+
+// parseAddr parses the argument at the index as a hex address, with or without a 0x
+// prefix, as the addresses are shown.
+func parseAddr(args []string, idx int) (uint16, error) {
+	if idx >= len(args) {
+		return 0, fmt.Errorf("missing argument")
+	}
+
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(args[idx]), "0x"), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q, must be hex", args[idx])
+	}
+
+	return uint16(v), nil
+}
+
+// parseKey parses the argument at the index as a hex keypad key, as on the keypad itself.
+func parseKey(args []string, idx int) (byte, error) {
+	if idx >= len(args) {
+		return 0, fmt.Errorf("missing argument")
+	}
+
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(args[idx]), "0x"), 16, 8)
+	if err != nil || v >= emulator.KeyCount {
+		return 0, fmt.Errorf("invalid key %q, must be 0-F", args[idx])
+	}
+
+	return byte(v), nil
+}
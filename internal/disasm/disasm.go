@@ -0,0 +1,249 @@
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jamrig/chippy/internal/emulator"
+)
+
+// Line is a single line of a listing, either an instruction or a run of data bytes.
+type Line struct {
+	// Address is the address of the first byte.
+	Address uint16
+	// Bytes are the raw bytes of the line.
+	Bytes []byte
+	// Code is true if the line is an instruction.
+	Code bool
+	// Text is the mnemonic for code, or the data directive for data.
+	Text string
+}
+
+// Listing is the disassembly of a ROM.
+type Listing struct {
+	// Lines are the lines in address order.
+	Lines []Line
+	// Labels are the names of the branch, call and index targets.
+	Labels map[uint16]string
+}
+
+// Disassembler walks a ROM from its entry point, following jumps and calls to tell code from data.
+type Disassembler struct {
+	// Instructions is the instruction set to decode with.
+	Instructions []emulator.Instruction
+	// Origin is the address the ROM is loaded at.
+	Origin uint16
+	// Variant is the variant the ROM is for.
+	Variant emulator.Variant
+}
+
+// New returns a new Disassembler for the config.
+func New(config *emulator.Config) *Disassembler {
+	return &Disassembler{
+		Instructions: emulator.InstructionSets[config.Variant],
+		Origin:       config.Memory.ProgramAddress,
+		Variant:      config.Variant,
+	}
+}
+
+// Disassemble returns the listing of the ROM.
+func (d *Disassembler) Disassemble(rom []byte) *Listing {
+	code := map[uint16]int{}
+	labels := map[uint16]string{}
+	end := int(d.Origin) + len(rom)
+
+	inROM := func(addr uint16) bool {
+		return int(addr) >= int(d.Origin) && int(addr)+1 < end
+	}
+
+	word := func(addr uint16) uint16 {
+		i := int(addr) - int(d.Origin)
+		return uint16(rom[i])<<8 | uint16(rom[i+1])
+	}
+
+	label := func(addr uint16, prefix string) {
+		if _, ok := labels[addr]; !ok {
+			labels[addr] = fmt.Sprintf("%s%04X", prefix, addr)
+		}
+	}
+
+	queue := []uint16{d.Origin}
+	for len(queue) > 0 {
+		addr := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		for inROM(addr) {
+			if _, seen := code[addr]; seen {
+				break
+			}
+
+			raw := word(addr)
+			op := emulator.NewOpcode(raw)
+			if op.Decode(d.Instructions) == nil {
+				break
+			}
+
+			size := 2
+			if d.Variant == emulator.VariantXOCHIP && raw == 0xF000 {
+				size = 4
+			}
+			code[addr] = size
+
+			next := addr + uint16(size)
+
+			switch {
+			case raw == 0x00EE || raw == 0x00FD:
+				next = 0
+			case op.F == 0x1:
+				label(op.NNN, "L")
+				queue = append(queue, op.NNN)
+				next = 0
+			case op.F == 0xB:
+				label(op.NNN, "L")
+				queue = append(queue, op.NNN)
+				next = 0
+			case op.F == 0x2:
+				label(op.NNN, "S")
+				queue = append(queue, op.NNN)
+			case op.F == 0xA:
+				label(op.NNN, "D")
+			case size == 4 && inROM(addr+2):
+				label(word(addr+2), "D")
+			case isSkip(op):
+				// Both the next instruction and the one after it can be reached.
+				after := next + 2
+				if d.Variant == emulator.VariantXOCHIP && inROM(next) && word(next) == 0xF000 {
+					after = next + 4
+				}
+				queue = append(queue, after)
+			}
+
+			if next == 0 {
+				break
+			}
+
+			addr = next
+		}
+	}
+
+	return d.listing(rom, code, labels)
+}
+
+// isSkip returns true if the opcode is a conditional skip.
+func isSkip(op emulator.Opcode) bool {
+	switch op.F {
+	case 0x3, 0x4, 0x9:
+		return true
+	case 0x5:
+		return op.N == 0
+	case 0xE:
+		return op.NN == 0x9E || op.NN == 0xA1
+	}
+
+	return false
+}
+
+// listing builds the lines from the code map, with everything else as data.
+func (d *Disassembler) listing(rom []byte, code map[uint16]int, labels map[uint16]string) *Listing {
+	l := &Listing{
+		Lines:  []Line{},
+		Labels: labels,
+	}
+
+	for i := 0; i < len(rom); {
+		addr := d.Origin + uint16(i)
+
+		if size, ok := code[addr]; ok && i+size <= len(rom) {
+			raw := uint16(rom[i])<<8 | uint16(rom[i+1])
+			long := uint16(0)
+			if size == 4 {
+				long = uint16(rom[i+2])<<8 | uint16(rom[i+3])
+			}
+
+			op := emulator.NewOpcode(raw)
+			l.Lines = append(l.Lines, Line{
+				Address: addr,
+				Bytes:   rom[i : i+size],
+				Code:    true,
+				Text:    Mnemonic(op.Decode(d.Instructions), raw, long, labels),
+			})
+
+			i += size
+			continue
+		}
+
+		// Group data bytes up to the next code, label or 8 bytes.
+		j := i + 1
+		for j < len(rom) && j-i < 8 {
+			a := d.Origin + uint16(j)
+			if _, ok := code[a]; ok {
+				break
+			}
+			if _, ok := labels[a]; ok {
+				break
+			}
+			j++
+		}
+
+		hex := []string{}
+		for _, b := range rom[i:j] {
+			hex = append(hex, fmt.Sprintf("0x%02X", b))
+		}
+
+		l.Lines = append(l.Lines, Line{
+			Address: addr,
+			Bytes:   rom[i:j],
+			Code:    false,
+			Text:    "db " + strings.Join(hex, " "),
+		})
+
+		i = j
+	}
+
+	return l
+}
+
+// Write writes the listing with labels, addresses and raw opcodes. Labels which are not
+// at the start of a line, such as the font addresses below the ROM, are written first as
+// :const lines, so that every label used as an operand is defined in the listing.
+func (l *Listing) Write(w io.Writer) error {
+	starts := map[uint16]bool{}
+	for _, line := range l.Lines {
+		starts[line.Address] = true
+	}
+
+	consts := []uint16{}
+	for addr := range l.Labels {
+		if !starts[addr] {
+			consts = append(consts, addr)
+		}
+	}
+	sort.Slice(consts, func(i, j int) bool { return consts[i] < consts[j] })
+
+	for _, addr := range consts {
+		if _, err := fmt.Fprintf(w, ":const %s 0x%04X\n", l.Labels[addr], addr); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range l.Lines {
+		if label, ok := l.Labels[line.Address]; ok {
+			if _, err := fmt.Fprintf(w, "%s:\n", label); err != nil {
+				return err
+			}
+		}
+
+		raw := ""
+		for _, b := range line.Bytes {
+			raw += fmt.Sprintf("%02X", b)
+		}
+
+		if _, err := fmt.Fprintf(w, "  %04X  %-16s  %s\n", line.Address, raw, line.Text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jamrig/chippy/internal/emulator"
+	"github.com/stretchr/testify/assert"
+)
+
+// line is the part of a Line the tests compare.
+type line struct {
+	Address uint16
+	Code    bool
+	Text    string
+}
+
+func lines(l *Listing) []line {
+	out := []line{}
+	for _, ln := range l.Lines {
+		out = append(out, line{ln.Address, ln.Code, ln.Text})
+	}
+
+	return out
+}
+
+func TestDisassemble(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *emulator.Config
+		rom    []byte
+		lines  []line
+		labels map[uint16]string
+	}{
+		{
+			name:   "code and data",
+			config: emulator.CHIP8Config,
+			rom:    []byte{0xA2, 0x06, 0x60, 0x01, 0x12, 0x04, 0xF0, 0x90},
+			lines: []line{
+				{0x200, true, "Set Index D0206"},
+				{0x202, true, "V0 = 0x01"},
+				{0x204, true, "Jump L0204"},
+				{0x206, false, "db 0xF0 0x90"},
+			},
+			labels: map[uint16]string{0x204: "L0204", 0x206: "D0206"},
+		},
+		{
+			name:   "skip reaches both",
+			config: emulator.CHIP8Config,
+			rom:    []byte{0x30, 0x01, 0x12, 0x08, 0x12, 0x04, 0xFF, 0xFF, 0x12, 0x08},
+			lines: []line{
+				{0x200, true, "Skip If V0 == 0x01"},
+				{0x202, true, "Jump L0208"},
+				{0x204, true, "Jump L0204"},
+				{0x206, false, "db 0xFF 0xFF"},
+				{0x208, true, "Jump L0208"},
+			},
+			labels: map[uint16]string{0x204: "L0204", 0x208: "L0208"},
+		},
+		{
+			name:   "long index",
+			config: emulator.XOCHIPConfig,
+			rom:    []byte{0xF0, 0x00, 0x02, 0x06, 0x12, 0x04, 0xAA},
+			lines: []line{
+				{0x200, true, "Long Set Index D0206"},
+				{0x204, true, "Jump L0204"},
+				{0x206, false, "db 0xAA"},
+			},
+			labels: map[uint16]string{0x204: "L0204", 0x206: "D0206"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := New(c.config).Disassemble(c.rom)
+
+			assert.Equal(t, c.lines, lines(l))
+			assert.Equal(t, c.labels, l.Labels)
+		})
+	}
+}
+
+func TestWriteConstLabels(t *testing.T) {
+	// The index points at the font below the ROM, which has no line to label.
+	l := New(emulator.CHIP8Config).Disassemble([]byte{0xA0, 0x50, 0x12, 0x02})
+
+	sb := &strings.Builder{}
+	assert.NoError(t, l.Write(sb))
+
+	assert.Equal(t, ":const D0050 0x0050\n"+
+		"  0200  A050              Set Index D0050\n"+
+		"L0202:\n"+
+		"  0202  1202              Jump L0202\n", sb.String())
+}
+
+func TestMnemonic(t *testing.T) {
+	cases := []struct {
+		raw  uint16
+		long uint16
+		text string
+	}{
+		{0x00E0, 0, "Clear Screen"},
+		{0x1234, 0, "Jump L0234"},
+		{0x3A12, 0, "Skip If VA == 0x12"},
+		{0x8124, 0, "V1 += V2"},
+		{0xC13F, 0, "V1 = rand & 0x3F"},
+		{0xD125, 0, "Display V1, V2, 5"},
+		{0xF000, 0x1234, "Long Set Index 0x1234"},
+	}
+
+	labels := map[uint16]string{0x234: "L0234"}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%04X", c.raw), func(t *testing.T) {
+			instr := emulator.NewOpcode(c.raw).Decode(emulator.InstructionSets[emulator.VariantXOCHIP])
+			if !assert.NotNil(t, instr) {
+				return
+			}
+
+			assert.Equal(t, c.text, Mnemonic(instr, c.raw, c.long, labels))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package disasm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jamrig/chippy/internal/emulator"
+)
+
+// namePattern matches an instruction name such as "[8XY4] Vx += Vy".
+var namePattern = regexp.MustCompile(`^\[([0-9A-FXYN]{4})\] (.*)$`)
+
+// operandPattern matches the operand placeholders within an instruction name.
+var operandPattern = regexp.MustCompile(`\b(V[xXyY]|NNN|NN|N)\b`)
+
+// Operands returns the operand fields of the opcode named by the instruction pattern,
+// e.g. "DXYN" gives X, Y and N. The 4 character pattern maps one character to each nibble.
+func Operands(pattern string, raw uint16) map[string]uint16 {
+	operands := map[string]uint16{}
+
+	for i := 0; i < len(pattern); {
+		ch := pattern[i]
+		if ch != 'X' && ch != 'Y' && ch != 'N' {
+			i++
+			continue
+		}
+
+		j := i
+		value := uint16(0)
+		for j < len(pattern) && pattern[j] == ch {
+			value = value<<4 | (raw>>(4*(3-j)))&0x0F
+			j++
+		}
+
+		operands[strings.Repeat(string(ch), j-i)] = value
+		i = j
+	}
+
+	return operands
+}
+
+// Mnemonic returns the instruction name with the opcode operands substituted, using the
+// labels for address operands where there is one. Operands not named in the text are
+// appended. The long operand is the second word of a 4 byte instruction such as F000 NNNN.
+func Mnemonic(instr *emulator.Instruction, raw uint16, long uint16, labels map[uint16]string) string {
+	m := namePattern.FindStringSubmatch(instr.Name)
+	if m == nil {
+		return instr.Name
+	}
+
+	pattern, text := m[1], m[2]
+	operands := Operands(pattern, raw)
+	used := map[string]bool{}
+
+	format := func(field string) string {
+		used[field] = true
+		v := operands[field]
+
+		switch field {
+		case "X", "Y":
+			return fmt.Sprintf("V%X", v)
+		case "NNN":
+			if label, ok := labels[v]; ok {
+				return label
+			}
+			return fmt.Sprintf("0x%03X", v)
+		case "NN":
+			return fmt.Sprintf("0x%02X", v)
+		}
+
+		return fmt.Sprintf("%d", v)
+	}
+
+	text = operandPattern.ReplaceAllStringFunc(text, func(token string) string {
+		field := strings.ToUpper(token)
+		if field[0] == 'V' {
+			field = field[1:]
+		}
+
+		if _, ok := operands[field]; !ok {
+			return token
+		}
+
+		return format(field)
+	})
+
+	extra := []string{}
+	for _, field := range []string{"X", "Y", "NNN", "NN", "N"} {
+		if _, ok := operands[field]; ok && !used[field] {
+			extra = append(extra, format(field))
+		}
+	}
+
+	if pattern == "F000" {
+		if label, ok := labels[long]; ok {
+			extra = append(extra, label)
+		} else {
+			extra = append(extra, fmt.Sprintf("0x%04X", long))
+		}
+	}
+
+	if len(extra) > 0 {
+		text += " " + strings.Join(extra, ", ")
+	}
+
+	return text
+}
@@ -0,0 +1,172 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+// Tone describes the sound to be played.
+type Tone struct {
+	// Active is true while the sound timer is running.
+	Active bool
+	// Pattern is the XO-CHIP 1-bit audio pattern, nil to use a square wave.
+	Pattern *[16]byte
+	// Rate is the playback rate of the pattern in bits per second.
+	Rate float64
+}
+
+// PatternRate returns the XO-CHIP pattern playback rate in bits per second for the pitch.
+func PatternRate(pitch byte) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// AudioSink receives the state of the sound timer and produces sound.
+type AudioSink interface {
+	// Update is called every cycle with the elapsed time in nanoseconds and the tone to play.
+	Update(delta int64, tone Tone)
+	// Close flushes any output and frees the acquired resources.
+	Close() error
+}
+
+// NullSink is an AudioSink which discards all sound.
+type NullSink struct{}
+
+// Update does nothing.
+func (s *NullSink) Update(delta int64, tone Tone) {}
+
+// Close does nothing.
+func (s *NullSink) Close() error {
+	return nil
+}
+
+// BellSink is an AudioSink which rings the terminal bell when the tone starts.
+type BellSink struct {
+	Out    io.Writer
+	Active bool
+}
+
+// NewBellSink returns a new BellSink.
+func NewBellSink(out io.Writer) *BellSink {
+	return &BellSink{
+		Out:    out,
+		Active: false,
+	}
+}
+
+// Update rings the bell if the tone has just become active.
+func (s *BellSink) Update(delta int64, tone Tone) {
+	if tone.Active && !s.Active {
+		s.Out.Write([]byte("\a"))
+	}
+
+	s.Active = tone.Active
+}
+
+// Close does nothing.
+func (s *BellSink) Close() error {
+	return nil
+}
+
+// WAVSink is an AudioSink which records the tone to a 16-bit mono PCM WAV file.
+// The tone is a square wave unless an audio pattern is set.
+type WAVSink struct {
+	Config *AudioConfig
+	Path   string
+	// Samples are the generated samples.
+	Samples []int16
+	// pending is the fractional number of samples not yet generated.
+	pending float64
+	// phase is the position within the current square-wave period in samples,
+	// or within the audio pattern in bits.
+	phase float64
+}
+
+// NewWAVSink returns a new WAVSink which writes to the path on Close.
+func NewWAVSink(config *AudioConfig, path string) *WAVSink {
+	return &WAVSink{
+		Config:  config,
+		Path:    path,
+		Samples: []int16{},
+	}
+}
+
+// Update generates the samples for the elapsed time.
+func (s *WAVSink) Update(delta int64, tone Tone) {
+	s.pending += float64(delta) * float64(s.Config.SampleRate) / 1e9
+
+	period := float64(s.Config.SampleRate) / float64(s.Config.ToneFrequency)
+	amplitude := int16(32767 * s.Config.Volume / 100)
+
+	for ; s.pending >= 1; s.pending-- {
+		if !tone.Active {
+			s.phase = 0
+			s.Samples = append(s.Samples, 0)
+			continue
+		}
+
+		if tone.Pattern != nil {
+			bit := int(s.phase) % 128
+			if GetBitAtPosition(tone.Pattern[bit/8], 7-bit%8) > 0 {
+				s.Samples = append(s.Samples, amplitude)
+			} else {
+				s.Samples = append(s.Samples, -amplitude)
+			}
+
+			s.phase = math.Mod(s.phase+tone.Rate/float64(s.Config.SampleRate), 128)
+			continue
+		}
+
+		if s.phase < period/2 {
+			s.Samples = append(s.Samples, amplitude)
+		} else {
+			s.Samples = append(s.Samples, -amplitude)
+		}
+
+		s.phase++
+		if s.phase >= period {
+			s.phase -= period
+		}
+	}
+}
+
+// Close writes the WAV file.
+func (s *WAVSink) Close() error {
+	f, err := os.Create(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return WriteWAV(f, s.Config.SampleRate, s.Samples)
+}
+
+// WriteWAV writes 16-bit mono PCM samples as a WAV file.
+func WriteWAV(w io.Writer, sampleRate int, samples []int16) error {
+	dataSize := uint32(len(samples) * 2)
+
+	header := []any{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + dataSize),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),
+		uint16(1),
+		uint16(1),
+		uint32(sampleRate),
+		uint32(sampleRate * 2),
+		uint16(2),
+		uint16(16),
+		[4]byte{'d', 'a', 't', 'a'},
+		dataSize,
+	}
+
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	return binary.Write(w, binary.LittleEndian, samples)
+}
@@ -0,0 +1,52 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadlessWAV(t *testing.T) {
+	config := CHIP8Config.Clone()
+	file := filepath.Join(t.TempDir(), "sound.wav")
+	sink := NewWAVSink(config.Audio, file)
+	e := newTestEmulator(t, config, ": main v0 := 30 buzzer := v0 loop again", WithAudio(sink))
+
+	// One second of emulated time, with the buzzer on for the first half.
+	_, err := e.RunHeadless(HeadlessLimits{Instructions: uint64(config.CPU.InstructionTimerFrequency)})
+	if !assert.NoError(t, err) || !assert.NoError(t, sink.Close()) {
+		return
+	}
+
+	data, err := os.ReadFile(file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rate := config.Audio.SampleRate
+	samples := (len(data) - 44) / 2
+
+	assert.Equal(t, "RIFF", string(data[0:4]))
+	assert.Equal(t, uint32(len(data)-8), binary.LittleEndian.Uint32(data[4:8]))
+	assert.Equal(t, "WAVEfmt ", string(data[8:16]))
+	assert.Equal(t, uint16(1), binary.LittleEndian.Uint16(data[20:22]), "PCM")
+	assert.Equal(t, uint16(1), binary.LittleEndian.Uint16(data[22:24]), "channels")
+	assert.Equal(t, uint32(rate), binary.LittleEndian.Uint32(data[24:28]))
+	assert.Equal(t, uint16(16), binary.LittleEndian.Uint16(data[34:36]), "bits per sample")
+	assert.Equal(t, "data", string(data[36:40]))
+	assert.Equal(t, uint32(samples*2), binary.LittleEndian.Uint32(data[40:44]))
+	assert.InDelta(t, rate, samples, 1)
+
+	sound := 0
+	for i := 0; i < samples; i++ {
+		if binary.LittleEndian.Uint16(data[44+i*2:]) != 0 {
+			sound++
+		}
+	}
+
+	// Half a second of sound, give or take a frame.
+	assert.InDelta(t, rate/2, sound, float64(rate/60))
+}
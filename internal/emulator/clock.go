@@ -0,0 +1,92 @@
+package emulator
+
+import "time"
+
+// Clock is the source of time for the emulator. The deltas it returns drive the CPU,
+// display and timers, so a fixed-step clock makes a run independent of the host.
+type Clock interface {
+	// Tick returns the time in nanoseconds since the previous tick.
+	Tick() int64
+	// Wait blocks until the next tick is due.
+	Wait()
+}
+
+// WallClock is a Clock which follows real time.
+type WallClock struct {
+	// Interval is the time in nanoseconds between ticks.
+	Interval int64
+	last     int64
+}
+
+// NewWallClock returns a new WallClock which ticks at most once per interval in nanoseconds.
+func NewWallClock(interval int64) *WallClock {
+	return &WallClock{
+		Interval: interval,
+		last:     time.Now().UnixNano(),
+	}
+}
+
+// Tick returns the real time since the previous tick.
+func (c *WallClock) Tick() int64 {
+	now := time.Now().UnixNano()
+	delta := now - c.last
+	c.last = now
+
+	return delta
+}
+
+// Wait sleeps for the rest of the interval since the previous tick.
+func (c *WallClock) Wait() {
+	if remaining := c.Interval - (time.Now().UnixNano() - c.last); remaining > 0 {
+		time.Sleep(time.Duration(remaining))
+	}
+}
+
+// VirtualClock is a Clock which advances by a fixed step on every tick.
+type VirtualClock struct {
+	// Step is the time in nanoseconds added on each tick.
+	Step int64
+	// Time is the total virtual time in nanoseconds.
+	Time int64
+}
+
+// NewVirtualClock returns a new VirtualClock with the step in nanoseconds.
+func NewVirtualClock(step int64) *VirtualClock {
+	return &VirtualClock{
+		Step: step,
+		Time: 0,
+	}
+}
+
+// Tick advances the virtual time by the step.
+func (c *VirtualClock) Tick() int64 {
+	c.Time += c.Step
+
+	return c.Step
+}
+
+// Wait returns immediately, as virtual time does not pass on its own.
+func (c *VirtualClock) Wait() {}
+
+// PacedClock is a VirtualClock which waits for real time to catch up, so a fixed-step
+// run plays at normal speed. Movies use it so that recording and playback see the
+// same deltas.
+type PacedClock struct {
+	VirtualClock
+	start int64
+}
+
+// NewPacedClock returns a new PacedClock with the step in nanoseconds.
+func NewPacedClock(step int64) *PacedClock {
+	return &PacedClock{
+		VirtualClock: VirtualClock{Step: step, Time: 0},
+		start:        time.Now().UnixNano(),
+	}
+}
+
+// Wait sleeps while the virtual time is ahead of the real time.
+func (c *PacedClock) Wait() {
+	if ahead := c.Time - (time.Now().UnixNano() - c.start); ahead > 0 {
+		time.Sleep(time.Duration(ahead))
+	}
+}
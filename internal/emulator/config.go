@@ -1,69 +1,593 @@
 package emulator
 
+import "fmt"
+
+// Variant is a CHIP-8 system variant, which determines the available instructions.
+type Variant int
+
+const (
+	// VariantCHIP8 is the original CHIP-8 (Cosmac VIP).
+	VariantCHIP8 Variant = iota
+	// VariantSCHIP is SUPER-CHIP 1.1.
+	VariantSCHIP
+	// VariantXOCHIP is XO-CHIP.
+	VariantXOCHIP
+)
+
+// ParseVariant returns the variant with the name.
+func ParseVariant(name string) (Variant, error) {
+	for _, v := range []Variant{VariantCHIP8, VariantSCHIP, VariantXOCHIP} {
+		if v.String() == name {
+			return v, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown variant %q", name)
+}
+
+// String returns the name of the variant.
+func (v Variant) String() string {
+	switch v {
+	case VariantCHIP8:
+		return "chip-8"
+	case VariantSCHIP:
+		return "schip"
+	case VariantXOCHIP:
+		return "xo-chip"
+	}
+
+	return "unknown"
+}
+
+// MarshalText returns the name of the variant.
+func (v Variant) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText sets the variant from its name.
+func (v *Variant) UnmarshalText(text []byte) error {
+	variant, err := ParseVariant(string(text))
+	if err != nil {
+		return err
+	}
+
+	*v = variant
+
+	return nil
+}
+
 // Config contains the config for the emulator.
 type Config struct {
-	CPU     *CPUConfig
-	Memory  *MemoryConfig
-	Display *DisplayConfig
+	Variant Variant        `yaml:"variant" json:"variant"`
+	CPU     *CPUConfig     `yaml:"cpu" json:"cpu"`
+	Memory  *MemoryConfig  `yaml:"memory" json:"memory"`
+	Display *DisplayConfig `yaml:"display" json:"display"`
+	Input   *InputConfig   `yaml:"input" json:"input"`
+	Audio   *AudioConfig   `yaml:"audio" json:"audio"`
+	Rewind  *RewindConfig  `yaml:"rewind" json:"rewind"`
+	Capture *CaptureConfig `yaml:"capture" json:"capture"`
 }
 
 // CPUConfig contains the config for the CPU.
 type CPUConfig struct {
 	// StackInitialSize is the initial size of the stack.
-	StackInitialSize int
+	StackInitialSize int `yaml:"stackInitialSize" json:"stackInitialSize"`
 	// InstructionTimerFrequency is the frequency of the instruction timer.
-	InstructionTimerFrequency int
+	InstructionTimerFrequency int `yaml:"instructionTimerFrequency" json:"instructionTimerFrequency"`
 	// DelayTimerFrequency is the frequency of the delay timer.
-	DelayTimerFrequency int
+	DelayTimerFrequency int `yaml:"delayTimerFrequency" json:"delayTimerFrequency"`
+	// SoundTimerFrequency is the frequency of the sound timer.
+	SoundTimerFrequency int `yaml:"soundTimerFrequency" json:"soundTimerFrequency"`
 	// InstructionAssignBeforeShift if true then assign Vy to Vx before shifting.
-	InstructionAssignBeforeShift bool
+	InstructionAssignBeforeShift bool `yaml:"instructionAssignBeforeShift" json:"instructionAssignBeforeShift"`
 	// InstructionUseVxForOffset if true then use Vx for offset rather than V0.
-	InstructionUseVxForOffset bool
+	InstructionUseVxForOffset bool `yaml:"instructionUseVxForOffset" json:"instructionUseVxForOffset"`
 	// InstructionOverflowAddIndex if true then will set overflow flag for index addition.
-	InstructionOverflowAddIndex bool
+	InstructionOverflowAddIndex bool `yaml:"instructionOverflowAddIndex" json:"instructionOverflowAddIndex"`
 	// InstructionModifyIndexOnStoreAndLoad if true then I will be modified with Store and Load.
-	InstructionModifyIndexOnStoreAndLoad bool
+	InstructionModifyIndexOnStoreAndLoad bool `yaml:"instructionModifyIndexOnStoreAndLoad" json:"instructionModifyIndexOnStoreAndLoad"`
+	// InstructionResetFlagOnLogic if true then VF is reset to 0 by the OR, AND and XOR instructions.
+	InstructionResetFlagOnLogic bool `yaml:"instructionResetFlagOnLogic" json:"instructionResetFlagOnLogic"`
+	// InstructionWaitForDisplay if true then drawing a sprite waits for the next display refresh.
+	InstructionWaitForDisplay bool `yaml:"instructionWaitForDisplay" json:"instructionWaitForDisplay"`
+	// InstructionClipSprites if true then sprites are clipped at the screen edges rather than wrapped.
+	InstructionClipSprites bool `yaml:"instructionClipSprites" json:"instructionClipSprites"`
+	// Seed is the seed for the random number generator, nil to seed from the current time.
+	Seed *int64 `yaml:"seed,omitempty" json:"seed,omitempty"`
+	// StackLimit is the number of nested calls before a stack overflow fault, 0 for no limit.
+	StackLimit int `yaml:"stackLimit" json:"stackLimit"`
+	// Faults are the policies for the machine faults.
+	Faults FaultConfig `yaml:"faults" json:"faults"`
+}
+
+// FaultConfig contains the policy for each kind of machine fault.
+type FaultConfig struct {
+	UnknownOpcode  FaultPolicy `yaml:"unknownOpcode" json:"unknownOpcode"`
+	StackUnderflow FaultPolicy `yaml:"stackUnderflow" json:"stackUnderflow"`
+	StackOverflow  FaultPolicy `yaml:"stackOverflow" json:"stackOverflow"`
+	PCOutOfBounds  FaultPolicy `yaml:"pcOutOfBounds" json:"pcOutOfBounds"`
+	ProtectedWrite FaultPolicy `yaml:"protectedWrite" json:"protectedWrite"`
+}
+
+// Policy returns the policy for the kind of fault.
+func (f FaultConfig) Policy(kind FaultKind) FaultPolicy {
+	switch kind {
+	case FaultUnknownOpcode:
+		return f.UnknownOpcode
+	case FaultStackUnderflow:
+		return f.StackUnderflow
+	case FaultStackOverflow:
+		return f.StackOverflow
+	case FaultPCOutOfBounds:
+		return f.PCOutOfBounds
+	case FaultProtectedWrite:
+		return f.ProtectedWrite
+	}
+
+	return PolicyHalt
+}
+
+// DefaultFaults halt on faults which leave the program lost, and only log writes to the
+// interpreter area as some programs use it for scratch space.
+var DefaultFaults = FaultConfig{
+	UnknownOpcode:  PolicyHalt,
+	StackUnderflow: PolicyHalt,
+	StackOverflow:  PolicyHalt,
+	PCOutOfBounds:  PolicyHalt,
+	ProtectedWrite: PolicyLog,
 }
 
 // MemoryConfig contains the config for the Memory.
 type MemoryConfig struct {
 	// Size is the size of the memory in bytes.
-	Size uint16
+	Size int `yaml:"size" json:"size"`
 	// ProgramAddress is the address at which program data starts.
-	ProgramAddress uint16
+	ProgramAddress uint16 `yaml:"programAddress" json:"programAddress"`
 	// FontAddress is the address at which the font data starts.
-	FontAddress uint16
+	FontAddress uint16 `yaml:"fontAddress" json:"fontAddress"`
+	// BigFontAddress is the address at which the large font data starts.
+	BigFontAddress uint16 `yaml:"bigFontAddress" json:"bigFontAddress"`
 }
 
 // DisplayConfig contains the config for the Display.
 type DisplayConfig struct {
 	// Width is the width of the display.
-	Width int
+	Width int `yaml:"width" json:"width"`
 	// Height is the height of the display.
-	Height int
+	Height int `yaml:"height" json:"height"`
+	// HighResWidth is the width of the display in high-resolution mode, 0 if not supported.
+	HighResWidth int `yaml:"highResWidth" json:"highResWidth"`
+	// HighResHeight is the height of the display in high-resolution mode, 0 if not supported.
+	HighResHeight int `yaml:"highResHeight" json:"highResHeight"`
+	// Planes is the number of bitplanes.
+	Planes int `yaml:"planes" json:"planes"`
 	// Frequency is the frequency of the display timer.
-	Frequency int
+	Frequency int `yaml:"frequency" json:"frequency"`
+	// Scale is the number of times each pixel is repeated across and down, 0 to fit the terminal.
+	// In the image modes it is the number of image pixels per display pixel.
+	Scale int `yaml:"scale" json:"scale"`
+	// Mode is how pixels are drawn in the terminal.
+	Mode RenderMode `yaml:"mode" json:"mode"`
+	// SyncOutput if true then frames are wrapped in synchronized output sequences, which
+	// terminals without support ignore.
+	SyncOutput bool `yaml:"syncOutput" json:"syncOutput"`
+	// Theme is the name of the colour theme, see Themes.
+	Theme string `yaml:"theme" json:"theme"`
+	// Colors are "#rrggbb" colours which replace the theme colours in order: unset pixels,
+	// plane 1, plane 2 and both planes.
+	Colors []string `yaml:"colors" json:"colors"`
+	// Foreground is the 256-colour palette index of set pixels, -1 to use the theme.
+	Foreground int `yaml:"foreground" json:"foreground"`
+	// Background is the 256-colour palette index of unset pixels, -1 to use the theme.
+	Background int `yaml:"background" json:"background"`
+	// Color is how colours are written to the terminal.
+	Color ColorMode `yaml:"color" json:"color"`
+}
+
+// ColorMode is how the terminal Window writes colours.
+type ColorMode string
+
+const (
+	// ColorAuto uses 24-bit colour if the terminal advertises it in COLORTERM, and 256 colours otherwise.
+	ColorAuto ColorMode = "auto"
+	// ColorTrue uses 24-bit colour.
+	ColorTrue ColorMode = "truecolor"
+	// Color256 uses the nearest colours of the xterm 256-colour palette.
+	Color256 ColorMode = "256"
+)
+
+// Validate returns an error if the colour mode is unknown.
+func (m ColorMode) Validate() error {
+	switch m {
+	case ColorAuto, ColorTrue, Color256:
+		return nil
+	}
+
+	return fmt.Errorf("unknown colour mode %q", m)
+}
+
+// RenderMode is how the terminal Window draws pixels.
+type RenderMode string
+
+const (
+	// RenderBlock draws each pixel as a cell in reverse video.
+	RenderBlock RenderMode = "block"
+	// RenderHalfBlock draws 1x2 pixels per cell with the half-block characters, so pixels are square.
+	RenderHalfBlock RenderMode = "half-block"
+	// RenderBraille draws 2x4 pixels per cell with the braille patterns.
+	RenderBraille RenderMode = "braille"
+	// RenderKitty draws the display as an image with the Kitty graphics protocol.
+	RenderKitty RenderMode = "kitty"
+	// RenderSixel draws the display as an image with Sixel graphics.
+	RenderSixel RenderMode = "sixel"
+)
+
+// Validate returns an error if the render mode is unknown.
+func (m RenderMode) Validate() error {
+	switch m {
+	case RenderBlock, RenderHalfBlock, RenderBraille, RenderKitty, RenderSixel:
+		return nil
+	}
+
+	return fmt.Errorf("unknown render mode %q", m)
+}
+
+// InputConfig contains the config for the terminal input.
+type InputConfig struct {
+	// Keymap maps terminal key names to keypad keys.
+	Keymap map[string]byte `yaml:"keymap" json:"keymap"`
+	// HoldTimeout is the time in milliseconds a key is held after its last keystroke,
+	// since terminals do not send key release events. It is longer than the usual
+	// autorepeat delay, so a held key stays pressed until its repeats arrive.
+	HoldTimeout int `yaml:"holdTimeout" json:"holdTimeout"`
+	// Hotkeys maps terminal key names to emulator actions such as quick-save.
+	Hotkeys map[string]string `yaml:"hotkeys" json:"hotkeys"`
+}
+
+// AudioConfig contains the config for the audio output.
+type AudioConfig struct {
+	// ToneFrequency is the frequency of the square-wave tone in Hz.
+	ToneFrequency int `yaml:"toneFrequency" json:"toneFrequency"`
+	// SampleRate is the sample rate of generated audio in Hz.
+	SampleRate int `yaml:"sampleRate" json:"sampleRate"`
+	// Volume is the volume of generated audio from 0 to 100.
+	Volume int `yaml:"volume" json:"volume"`
+}
+
+// RewindConfig contains the config for the rewind buffer.
+type RewindConfig struct {
+	// Budget is the memory budget for the rewind snapshots in bytes, 0 disables rewinding.
+	Budget int `yaml:"budget" json:"budget"`
+}
+
+// CaptureConfig contains the config for screenshots and GIF recordings.
+type CaptureConfig struct {
+	// Scale is the number of image pixels per low-resolution display pixel, so high
+	// resolution captures are the same size.
+	Scale int `yaml:"scale" json:"scale"`
+	// GIFLimit is the longest a GIF recorded with the hotkey can be in seconds, 0 for no limit.
+	GIFLimit int `yaml:"gifLimit" json:"gifLimit"`
+}
+
+// DefaultKeymap is the usual 1234/QWER/ASDF/ZXCV layout for the keypad.
+var DefaultKeymap = map[string]byte{
+	"1": 0x1, "2": 0x2, "3": 0x3, "4": 0xC,
+	"q": 0x4, "w": 0x5, "e": 0x6, "r": 0xD,
+	"a": 0x7, "s": 0x8, "d": 0x9, "f": 0xE,
+	"z": 0xA, "x": 0x0, "c": 0xB, "v": 0xF,
+}
+
+// DefaultHotkeys are the default hotkeys for the emulator actions.
+var DefaultHotkeys = map[string]string{
+	"f5":        ActionQuickSave,
+	"f9":        ActionQuickLoad,
+	"backspace": ActionRewind,
+	"[":         ActionRewindStep,
+	"p":         ActionPause,
+	"f6":        ActionScreenshot,
+	"f7":        ActionRecordGIF,
 }
 
 // CHIP8Config is the base config for a CHIP-8 system (Cosmac VIP).
 var CHIP8Config = &Config{
+	Variant: VariantCHIP8,
 	CPU: &CPUConfig{
 		StackInitialSize:                     32,
 		InstructionTimerFrequency:            700,
 		DelayTimerFrequency:                  60,
-		InstructionAssignBeforeShift:         false,
+		SoundTimerFrequency:                  60,
+		InstructionAssignBeforeShift:         true,
 		InstructionUseVxForOffset:            false,
 		InstructionOverflowAddIndex:          false,
 		InstructionModifyIndexOnStoreAndLoad: true,
+		InstructionResetFlagOnLogic:          true,
+		InstructionWaitForDisplay:            true,
+		InstructionClipSprites:               true,
+		Seed:                                 nil,
+		StackLimit:                           16,
+		Faults:                               DefaultFaults,
 	},
 	Memory: &MemoryConfig{
 		Size:           4096,
 		ProgramAddress: 0x200,
 		FontAddress:    0x50,
+		BigFontAddress: 0xA0,
 	},
 	Display: &DisplayConfig{
-		Width:     64,
-		Height:    32,
-		Frequency: 60,
+		Width:      64,
+		Height:     32,
+		Planes:     1,
+		Frequency:  60,
+		Scale:      0,
+		Mode:       RenderHalfBlock,
+		SyncOutput: true,
+		Theme:      DefaultTheme,
+		Foreground: -1,
+		Background: -1,
+		Color:      ColorAuto,
+	},
+	Input: &InputConfig{
+		Keymap:      DefaultKeymap,
+		HoldTimeout: 600,
+		Hotkeys:     DefaultHotkeys,
+	},
+	Audio: &AudioConfig{
+		ToneFrequency: 440,
+		SampleRate:    44100,
+		Volume:        25,
+	},
+	Rewind: &RewindConfig{
+		Budget: 16 << 20,
+	},
+	Capture: &CaptureConfig{
+		Scale:    4,
+		GIFLimit: 30,
 	},
 }
+
+// CHIP48Config is the config for a CHIP-48 system (HP 48).
+var CHIP48Config = deriveConfig(CHIP8Config, func(c *Config) {
+	c.CPU.InstructionTimerFrequency = 1800
+	c.CPU.InstructionAssignBeforeShift = false
+	c.CPU.InstructionUseVxForOffset = true
+	c.CPU.InstructionModifyIndexOnStoreAndLoad = false
+	c.CPU.InstructionResetFlagOnLogic = false
+	c.CPU.InstructionWaitForDisplay = false
+})
+
+// SCHIPConfig is the base config for a SUPER-CHIP 1.1 system (HP 48), with the legacy quirks.
+var SCHIPConfig = &Config{
+	Variant: VariantSCHIP,
+	CPU: &CPUConfig{
+		StackInitialSize:                     32,
+		InstructionTimerFrequency:            1800,
+		DelayTimerFrequency:                  60,
+		SoundTimerFrequency:                  60,
+		InstructionAssignBeforeShift:         false,
+		InstructionUseVxForOffset:            true,
+		InstructionOverflowAddIndex:          false,
+		InstructionModifyIndexOnStoreAndLoad: false,
+		InstructionResetFlagOnLogic:          false,
+		InstructionWaitForDisplay:            true,
+		InstructionClipSprites:               true,
+		Seed:                                 nil,
+		StackLimit:                           16,
+		Faults:                               DefaultFaults,
+	},
+	Memory: &MemoryConfig{
+		Size:           4096,
+		ProgramAddress: 0x200,
+		FontAddress:    0x50,
+		BigFontAddress: 0xA0,
+	},
+	Display: &DisplayConfig{
+		Width:         64,
+		Height:        32,
+		HighResWidth:  128,
+		HighResHeight: 64,
+		Planes:        1,
+		Frequency:     60,
+		Scale:         0,
+		Mode:          RenderHalfBlock,
+		SyncOutput:    true,
+		Theme:         DefaultTheme,
+		Foreground:    -1,
+		Background:    -1,
+		Color:         ColorAuto,
+	},
+	Input: &InputConfig{
+		Keymap:      DefaultKeymap,
+		HoldTimeout: 600,
+		Hotkeys:     DefaultHotkeys,
+	},
+	Audio: &AudioConfig{
+		ToneFrequency: 440,
+		SampleRate:    44100,
+		Volume:        25,
+	},
+	Rewind: &RewindConfig{
+		Budget: 16 << 20,
+	},
+	Capture: &CaptureConfig{
+		Scale:    4,
+		GIFLimit: 30,
+	},
+}
+
+// SCHIPModernConfig is the config for SUPER-CHIP as implemented by modern interpreters such as Octo.
+var SCHIPModernConfig = deriveConfig(SCHIPConfig, func(c *Config) {
+	c.CPU.InstructionWaitForDisplay = false
+})
+
+// XOCHIPConfig is the base config for an XO-CHIP system (Octo).
+var XOCHIPConfig = &Config{
+	Variant: VariantXOCHIP,
+	CPU: &CPUConfig{
+		StackInitialSize:                     32,
+		InstructionTimerFrequency:            60000,
+		DelayTimerFrequency:                  60,
+		SoundTimerFrequency:                  60,
+		InstructionAssignBeforeShift:         true,
+		InstructionUseVxForOffset:            false,
+		InstructionOverflowAddIndex:          false,
+		InstructionModifyIndexOnStoreAndLoad: true,
+		InstructionResetFlagOnLogic:          false,
+		InstructionWaitForDisplay:            false,
+		InstructionClipSprites:               false,
+		Seed:                                 nil,
+		StackLimit:                           16,
+		Faults:                               DefaultFaults,
+	},
+	Memory: &MemoryConfig{
+		Size:           65536,
+		ProgramAddress: 0x200,
+		FontAddress:    0x50,
+		BigFontAddress: 0xA0,
+	},
+	Display: &DisplayConfig{
+		Width:         64,
+		Height:        32,
+		HighResWidth:  128,
+		HighResHeight: 64,
+		Planes:        2,
+		Frequency:     60,
+		Scale:         0,
+		Mode:          RenderHalfBlock,
+		SyncOutput:    true,
+		Theme:         DefaultTheme,
+		Foreground:    -1,
+		Background:    -1,
+		Color:         ColorAuto,
+	},
+	Input: &InputConfig{
+		Keymap:      DefaultKeymap,
+		HoldTimeout: 600,
+		Hotkeys:     DefaultHotkeys,
+	},
+	Audio: &AudioConfig{
+		ToneFrequency: 440,
+		SampleRate:    44100,
+		Volume:        25,
+	},
+	Rewind: &RewindConfig{
+		Budget: 16 << 20,
+	},
+	Capture: &CaptureConfig{
+		Scale:    4,
+		GIFLimit: 30,
+	},
+}
+
+// Profiles contains the named configs for each supported platform.
+var Profiles = map[string]*Config{
+	"vip":          CHIP8Config,
+	"chip-48":      CHIP48Config,
+	"schip-legacy": SCHIPConfig,
+	"schip-modern": SCHIPModernConfig,
+	"xo-chip":      XOCHIPConfig,
+}
+
+// GetProfile returns a copy of the named profile config.
+func GetProfile(name string) (*Config, error) {
+	config, ok := Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+
+	return config.Clone(), nil
+}
+
+// Clone returns a deep copy of the config.
+func (c *Config) Clone() *Config {
+	cpu := *c.CPU
+	memory := *c.Memory
+	display := *c.Display
+	display.Colors = append([]string(nil), c.Display.Colors...)
+	input := *c.Input
+	audio := *c.Audio
+	rewind := *c.Rewind
+	capture := *c.Capture
+
+	if c.CPU.Seed != nil {
+		seed := *c.CPU.Seed
+		cpu.Seed = &seed
+	}
+
+	input.Keymap = make(map[string]byte, len(c.Input.Keymap))
+	for k, v := range c.Input.Keymap {
+		input.Keymap[k] = v
+	}
+
+	input.Hotkeys = make(map[string]string, len(c.Input.Hotkeys))
+	for k, v := range c.Input.Hotkeys {
+		input.Hotkeys[k] = v
+	}
+
+	return &Config{
+		Variant: c.Variant,
+		CPU:     &cpu,
+		Memory:  &memory,
+		Display: &display,
+		Input:   &input,
+		Audio:   &audio,
+		Rewind:  &rewind,
+		Capture: &capture,
+	}
+}
+
+// Validate returns an error if the config cannot be run, such as a timer frequency which
+// is not positive or a memory size the variant cannot address.
+func (c *Config) Validate() error {
+	if c.Variant.String() == "unknown" {
+		return fmt.Errorf("unknown variant %d", c.Variant)
+	}
+
+	frequencies := []struct {
+		name  string
+		value int
+	}{
+		{"cpu.instructionTimerFrequency", c.CPU.InstructionTimerFrequency},
+		{"cpu.delayTimerFrequency", c.CPU.DelayTimerFrequency},
+		{"cpu.soundTimerFrequency", c.CPU.SoundTimerFrequency},
+		{"display.frequency", c.Display.Frequency},
+	}
+	for _, f := range frequencies {
+		if f.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", f.name, f.value)
+		}
+	}
+
+	size := c.Memory.Size
+	if c.Variant == VariantXOCHIP && size != 0x10000 {
+		return fmt.Errorf("%s needs 65536 bytes of memory, got %d", c.Variant, size)
+	}
+	if size <= int(c.Memory.ProgramAddress) || size > 0x10000 {
+		return fmt.Errorf("memory.size must be above the program address and at most 65536, got %d", size)
+	}
+
+	if c.Display.Width <= 0 || c.Display.Height <= 0 {
+		return fmt.Errorf("display size must be positive, got %dx%d", c.Display.Width, c.Display.Height)
+	}
+	if c.Display.Planes < 1 || c.Display.Planes > 2 {
+		return fmt.Errorf("display.planes must be 1 or 2, got %d", c.Display.Planes)
+	}
+
+	if err := c.Display.Mode.Validate(); err != nil {
+		return err
+	}
+	if err := c.Display.Color.Validate(); err != nil {
+		return err
+	}
+
+	_, err := c.Display.ParsePalette()
+
+	return err
+}
+
+// deriveConfig returns a copy of the base config with the changes applied.
+func deriveConfig(base *Config, changes func(c *Config)) *Config {
+	c := base.Clone()
+	changes(c)
+
+	return c
+}
@@ -0,0 +1,48 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		profile string
+		change  func(c *Config)
+		err     string
+	}{
+		{"profile", "vip", func(c *Config) {}, ""},
+		{"xo-chip profile", "xo-chip", func(c *Config) {}, ""},
+		{"zero ips", "vip", func(c *Config) { c.CPU.InstructionTimerFrequency = 0 }, "cpu.instructionTimerFrequency must be positive"},
+		{"negative ips", "vip", func(c *Config) { c.CPU.InstructionTimerFrequency = -5 }, "cpu.instructionTimerFrequency must be positive"},
+		{"zero delay timer", "vip", func(c *Config) { c.CPU.DelayTimerFrequency = 0 }, "cpu.delayTimerFrequency must be positive"},
+		{"zero display frequency", "vip", func(c *Config) { c.Display.Frequency = 0 }, "display.frequency must be positive"},
+		{"xo-chip on 4K", "xo-chip", func(c *Config) { c.Memory.Size = 4096 }, "xo-chip needs 65536 bytes of memory"},
+		{"memory below program", "vip", func(c *Config) { c.Memory.Size = 0x100 }, "memory.size must be above the program address"},
+		{"zero width", "vip", func(c *Config) { c.Display.Width = 0 }, "display size must be positive"},
+		{"three planes", "xo-chip", func(c *Config) { c.Display.Planes = 3 }, "display.planes must be 1 or 2"},
+		{"unknown mode", "vip", func(c *Config) { c.Display.Mode = "bogus" }, `unknown render mode "bogus"`},
+		{"unknown colour", "vip", func(c *Config) { c.Display.Color = "bogus" }, `unknown colour mode "bogus"`},
+		{"unknown theme", "vip", func(c *Config) { c.Display.Theme = "bogus" }, `unknown theme "bogus"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config, err := GetProfile(c.profile)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			c.change(config)
+
+			err = config.Validate()
+			if c.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile loads a YAML or JSON config file on top of a copy of the base config,
+// so that only the values present in the file are changed.
+func LoadConfigFile(file string, base *Config) (*Config, error) {
+	data, err := LoadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var unmarshal func([]byte, any) error
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		unmarshal = json.Unmarshal
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	default:
+		return nil, fmt.Errorf("config %s must be a .yaml, .yml or .json file", file)
+	}
+
+	// Decoding into a map merges with its existing entries, so a keymap or hotkeys
+	// set in the file replace the base ones rather than adding to them.
+	var maps struct {
+		Input struct {
+			Keymap  map[string]byte   `yaml:"keymap" json:"keymap"`
+			Hotkeys map[string]string `yaml:"hotkeys" json:"hotkeys"`
+		} `yaml:"input" json:"input"`
+	}
+
+	if err := unmarshal(data, &maps); err != nil {
+		return nil, fmt.Errorf("config %s: %w", file, err)
+	}
+
+	config := base.Clone()
+
+	if maps.Input.Keymap != nil {
+		config.Input.Keymap = nil
+	}
+
+	if maps.Input.Hotkeys != nil {
+		config.Input.Hotkeys = nil
+	}
+
+	if err := unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("config %s: %w", file, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", file, err)
+	}
+
+	return config, nil
+}
+
+// YAML returns the config as a YAML document.
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,57 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigFileReplacesMaps(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+	}{
+		{"config.yaml", "input:\n  keymap:\n    x: 0\n  hotkeys:\n    f9: quit\n"},
+		{"config.json", `{"input": {"keymap": {"x": 0}, "hotkeys": {"f9": "quit"}}}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), c.name)
+			assert.NoError(t, os.WriteFile(file, []byte(c.contents), 0o644))
+
+			config, err := LoadConfigFile(file, CHIP8Config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, map[string]byte{"x": 0}, config.Input.Keymap)
+			assert.Equal(t, map[string]string{"f9": "quit"}, config.Input.Hotkeys)
+			assert.Equal(t, DefaultKeymap, CHIP8Config.Input.Keymap, "base is unchanged")
+		})
+	}
+}
+
+func TestLoadConfigFileKeepsUnsetMaps(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("display:\n  scale: 3\n"), 0o644))
+
+	config, err := LoadConfigFile(file, CHIP8Config)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 3, config.Display.Scale)
+	assert.Equal(t, DefaultKeymap, config.Input.Keymap)
+	assert.Equal(t, DefaultHotkeys, config.Input.Hotkeys)
+}
+
+func TestLoadConfigFileValidates(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("cpu:\n  instructionTimerFrequency: 0\n"), 0o644))
+
+	_, err := LoadConfigFile(file, CHIP8Config)
+	assert.ErrorContains(t, err, "cpu.instructionTimerFrequency must be positive")
+}
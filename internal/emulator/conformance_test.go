@@ -0,0 +1,256 @@
+package emulator
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jamrig/chippy/internal/assembler"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the assembled sources")
+
+// conformanceCase runs a ROM headless for a number of frames and compares the final screen
+// against a golden screen in testdata/golden. The golden screens of the Timendus ROMs are
+// transcribed from the pass screens documented by the suite, so -update only rewrites the
+// screens of the assembled sources.
+type conformanceCase struct {
+	// name is the name of the golden screen.
+	name string
+	// rom is a ROM file in testdata/roms, or an Octo source file in testdata/asm.
+	rom     string
+	profile string
+	frames  int
+	// selection is written to 0x1FF, which the Timendus ROMs read to skip their menu.
+	selection byte
+	// inputs are played back as a movie, for the ROMs which wait for keys.
+	inputs []MovieInput
+}
+
+var conformanceCases = []conformanceCase{
+	{name: "font", rom: "font.8o", profile: "vip", frames: 30},
+	{name: "ibm-logo", rom: "2-ibm-logo.ch8", profile: "vip", frames: 60},
+	{name: "corax+", rom: "3-corax+.ch8", profile: "vip", frames: 60},
+	{name: "flags", rom: "4-flags.ch8", profile: "vip", frames: 120},
+	{
+		name:      "keypad-fx0a",
+		rom:       "6-keypad.ch8",
+		profile:   "vip",
+		frames:    120,
+		selection: 3,
+		inputs: []MovieInput{
+			{Frame: 40, Keys: 1 << 5},
+			{Frame: 50, Keys: 0},
+		},
+	},
+}
+
+// quirksSelection is the platform picked in the menu of the quirks ROM for each variant.
+var quirksSelection = map[Variant]byte{
+	VariantCHIP8:  1,
+	VariantSCHIP:  2,
+	VariantXOCHIP: 3,
+}
+
+func TestConformance(t *testing.T) {
+	for _, c := range conformanceCases {
+		t.Run(c.name, func(t *testing.T) {
+			runConformance(t, c)
+		})
+	}
+}
+
+func TestConformanceQuirks(t *testing.T) {
+	for name, profile := range Profiles {
+		c := conformanceCase{
+			name:      "quirks-" + name,
+			rom:       "5-quirks.ch8",
+			profile:   name,
+			frames:    1200,
+			selection: quirksSelection[profile.Variant],
+		}
+
+		t.Run(name, func(t *testing.T) {
+			runConformance(t, c)
+		})
+	}
+}
+
+// quirksExpected are the quirks the quirks ROM expects of each platform, from the table in
+// the test suite's documentation, so the profiles are checked even before the ROM is run.
+var quirksExpected = map[string]struct {
+	vfReset, memory, displayWait, clipping, shifting, jumping bool
+}{
+	"vip":          {vfReset: true, memory: true, displayWait: true, clipping: true},
+	"chip-48":      {clipping: true, shifting: true, jumping: true},
+	"schip-modern": {clipping: true, shifting: true, jumping: true},
+	"schip-legacy": {displayWait: true, clipping: true, shifting: true, jumping: true},
+	"xo-chip":      {memory: true},
+}
+
+func TestProfileQuirks(t *testing.T) {
+	for name := range Profiles {
+		assert.Contains(t, quirksExpected, name, "no expected quirks for profile")
+	}
+
+	for name, want := range quirksExpected {
+		t.Run(name, func(t *testing.T) {
+			config, err := GetProfile(name)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			cpu := config.CPU
+			assert.Equal(t, want.vfReset, cpu.InstructionResetFlagOnLogic, "vF reset")
+			assert.Equal(t, want.memory, cpu.InstructionModifyIndexOnStoreAndLoad, "memory")
+			assert.Equal(t, want.displayWait, cpu.InstructionWaitForDisplay, "display wait")
+			assert.Equal(t, want.clipping, cpu.InstructionClipSprites, "clipping")
+			assert.Equal(t, want.shifting, !cpu.InstructionAssignBeforeShift, "shifting")
+			assert.Equal(t, want.jumping, cpu.InstructionUseVxForOffset, "jumping")
+		})
+	}
+}
+
+func runConformance(t *testing.T, c conformanceCase) {
+	program := conformanceProgram(t, c.rom)
+
+	config, err := GetProfile(c.profile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	seed := int64(1)
+	config.CPU.Seed = &seed
+	config.Rewind.Budget = 0
+
+	e, err := NewFromProgram(config, program)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if c.selection != 0 {
+		e.Memory.Write(0x1FF, []byte{c.selection})
+	}
+
+	if len(c.inputs) > 0 {
+		movie := &Movie{
+			ROMHash:   HashROM(e.Program),
+			Seed:      seed,
+			Config:    config,
+			Inputs:    c.inputs,
+			Checksums: map[int]uint32{},
+			End:       c.frames,
+		}
+		if !assert.NoError(t, e.PlayMovie(movie)) {
+			return
+		}
+	}
+
+	_, err = e.RunHeadless(HeadlessLimits{Frames: c.frames})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	got := &bytes.Buffer{}
+	assert.NoError(t, e.Display.WriteText(got))
+
+	golden := filepath.Join("testdata", "golden", c.name+".txt")
+	assembled := filepath.Ext(c.rom) == ".8o"
+	if *update && assembled {
+		assert.NoError(t, os.WriteFile(golden, got.Bytes(), 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil && assembled {
+		t.Fatalf("no golden screen, check the output and rerun with -update: %v", err)
+	}
+	if err != nil {
+		t.Skipf("no golden screen, transcribe the pass screen documented by the test suite: %v\n%s", err, got)
+	}
+
+	if !bytes.Equal(want, got.Bytes()) {
+		t.Errorf("screen after %d frames does not match %s\n%s", c.frames, golden, screenDiff(string(want), got.String()))
+	}
+}
+
+// conformanceProgram returns the ROM, assembling Octo sources.
+// The test is skipped when a ROM is missing, as they are only fetched in CI.
+func conformanceProgram(t *testing.T, name string) []byte {
+	if filepath.Ext(name) != ".8o" {
+		rom := filepath.Join("testdata", "roms", name)
+		program, err := os.ReadFile(rom)
+		if err != nil {
+			t.Skipf("%s not found, fetch the ROMs with testdata/roms/fetch.sh: %v", rom, err)
+		}
+
+		return program
+	}
+
+	src, err := os.ReadFile(filepath.Join("testdata", "asm", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := assembler.Assemble(string(src))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	return program.ROM
+}
+
+// screenDiff returns the screens overlaid, with '+' for pixels set only in got, '-' for
+// pixels set only in want and a '>' in front of the rows which differ.
+func screenDiff(want, got string) string {
+	wantRows := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	gotRows := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	sb := &strings.Builder{}
+	if len(wantRows) != len(gotRows) || len(wantRows[0]) != len(gotRows[0]) {
+		fmt.Fprintf(sb, "size: want %dx%d, got %dx%d\n", len(wantRows[0]), len(wantRows), len(gotRows[0]), len(gotRows))
+	}
+
+	sb.WriteString("legend: + only in got, - only in want\n")
+
+	for y := 0; y < max(len(wantRows), len(gotRows)); y++ {
+		w := rowAt(wantRows, y)
+		g := rowAt(gotRows, y)
+
+		row := make([]byte, max(len(w), len(g)))
+		marker := byte(' ')
+		for x := range row {
+			ws := x < len(w) && w[x] == '#'
+			gs := x < len(g) && g[x] == '#'
+
+			switch {
+			case ws && gs:
+				row[x] = '#'
+			case gs:
+				row[x] = '+'
+				marker = '>'
+			case ws:
+				row[x] = '-'
+				marker = '>'
+			default:
+				row[x] = '.'
+			}
+		}
+
+		fmt.Fprintf(sb, "%c %s\n", marker, row)
+	}
+
+	return sb.String()
+}
+
+func rowAt(rows []string, y int) string {
+	if y < len(rows) {
+		return rows[y]
+	}
+
+	return ""
+}
@@ -1,59 +1,227 @@
 package emulator
 
+import "time"
+
 type CPU struct {
 	Config           *CPUConfig
+	Variant          Variant
+	Instructions     []Instruction
+	Dispatch         *DispatchTable
 	Memory           *Memory
 	Display          *Display
 	Stack            *Stack
+	Keypad           *Keypad
+	Rand             *RNG
 	DelayTimer       *Timer
+	SoundTimer       *Timer
 	InstructionTimer *Timer
+	// FontAddress is the address at which the font data starts.
+	FontAddress uint16
+	// BigFontAddress is the address at which the large font data starts.
+	BigFontAddress uint16
+	// ProgramAddress is the address at which the program starts, writes below it are protected.
+	ProgramAddress uint16
 	// PC is the program counter.
 	PC uint16
 	// I is the index register.
 	I uint16
 	// V are the general purpose registers.
 	V [16]uint8
+	// RPL are the SUPER-CHIP user flag registers.
+	RPL [16]uint8
+	// AudioPattern is the XO-CHIP audio pattern buffer.
+	AudioPattern [16]byte
+	// HasAudioPattern is true once an audio pattern has been loaded.
+	HasAudioPattern bool
+	// Pitch is the XO-CHIP audio pattern pitch.
+	Pitch byte
+	// Exited is true once the program has requested an exit.
+	Exited bool
+	// WaitingForKey is true while FX0A is blocking for a key press and release.
+	WaitingForKey bool
+	// WaitingForDisplay is true while DXYN is blocking for the next display refresh.
+	WaitingForDisplay bool
+	// DisplayWaitFrame is the display frame at which DXYN started waiting.
+	DisplayWaitFrame int
+	// Cycles is the number of instructions executed.
+	Cycles uint64
+	// OnFault is called with the faults whose policy is to log and continue.
+	OnFault func(f *Fault)
+	// fault is the fault raised by the current instruction which stops the machine.
+	fault *Fault
+	// opcode and opcodePC are the current instruction and its address.
+	opcode   uint16
+	opcodePC uint16
 }
 
-func NewCPU(config *CPUConfig, programAddress uint16, memory *Memory, display *Display) *CPU {
+func NewCPU(config *Config, memory *Memory, display *Display, keypad *Keypad) *CPU {
+	seed := time.Now().UnixNano()
+	if config.CPU.Seed != nil {
+		seed = *config.CPU.Seed
+	}
+
 	c := &CPU{
-		Config:           config,
-		Memory:           memory,
-		Display:          display,
-		Stack:            NewStack(config.StackInitialSize),
-		DelayTimer:       NewTimer(config.DelayTimerFrequency),
-		InstructionTimer: NewTimer(config.InstructionTimerFrequency),
-		PC:               programAddress,
-		I:                0,
-		V:                [16]byte{},
+		Config:            config.CPU,
+		Variant:           config.Variant,
+		Instructions:      InstructionSets[config.Variant],
+		Dispatch:          DispatchTableFor(config.Variant),
+		Memory:            memory,
+		Display:           display,
+		Stack:             NewStack(config.CPU.StackInitialSize),
+		Keypad:            keypad,
+		Rand:              NewRNG(seed),
+		DelayTimer:        NewCountdownTimer(config.CPU.DelayTimerFrequency),
+		SoundTimer:        NewCountdownTimer(config.CPU.SoundTimerFrequency),
+		InstructionTimer:  NewTimer(config.CPU.InstructionTimerFrequency),
+		FontAddress:       config.Memory.FontAddress,
+		BigFontAddress:    config.Memory.BigFontAddress,
+		ProgramAddress:    config.Memory.ProgramAddress,
+		PC:                config.Memory.ProgramAddress,
+		I:                 0,
+		V:                 [16]byte{},
+		RPL:               [16]byte{},
+		AudioPattern:      [16]byte{},
+		HasAudioPattern:   false,
+		Pitch:             64,
+		Exited:            false,
+		WaitingForKey:     false,
+		WaitingForDisplay: false,
+		DisplayWaitFrame:  0,
 	}
 
 	return c
 }
 
-// Tick will tick the contained timers and if ready will perform a full CPU cycle.
-func (c *CPU) Tick(delta int64) {
+// Tick will tick the contained timers and perform a full CPU cycle for each instruction
+// period in the delta, so the instruction rate does not depend on how often it is called.
+// It returns the fault which stopped the machine, if any.
+func (c *CPU) Tick(delta int64) error {
 	c.DelayTimer.Tick(delta)
+	c.SoundTimer.Tick(delta)
 
-	if c.InstructionTimer.Tick(delta) {
-		opcode := c.Fetch()
-		instr := opcode.Decode()
-		if instr == nil {
-			// TODO: handle nil
-		} else {
-			instr.Execute(c, opcode)
+	for due := c.InstructionTimer.Tick(delta); due && !c.Exited; due = c.InstructionTimer.Tick(0) {
+		if err := c.Step(); err != nil {
+			return err
 		}
 	}
+
+	return nil
 }
 
-// Fetch gets the next opcode and updates the PC.
-func (c *CPU) Fetch() *Opcode {
-	// TODO: reset PC if at end
+// Step performs a single fetch, decode and execute cycle. If the instruction raises a
+// fault whose policy stops the machine, the PC is left on the instruction and the fault
+// is returned.
+func (c *CPU) Step() error {
+	c.Cycles++
+	c.opcodePC = c.PC
+	c.opcode = 0
+
+	if int(c.PC)+1 >= c.Memory.Size {
+		if !c.Raise(FaultPCOutOfBounds, c.PC) {
+			return c.takeFault()
+		}
+
+		// Carrying on, the PC wraps around to the start of memory like an address
+		// counter would, rather than jumping back to the program.
+		c.PC = 0
+		c.opcodePC = c.PC
+	}
+
+	opcode := c.Fetch()
+	c.opcode = opcode.Raw
+
+	instr := c.Dispatch[opcode.Raw]
+	if instr == nil {
+		c.Raise(FaultUnknownOpcode, 0)
+	} else {
+		instr.Execute(c, opcode)
+	}
+
+	return c.takeFault()
+}
+
+// Raise raises a fault for the current instruction. If the policy is to log it then
+// OnFault is called and true is returned so the instruction carries on, otherwise the
+// fault is kept for Step to return and the instruction should stop.
+func (c *CPU) Raise(kind FaultKind, addr uint16) bool {
+	f := &Fault{
+		Kind:    kind,
+		Policy:  c.Config.Faults.Policy(kind),
+		PC:      c.opcodePC,
+		Opcode:  c.opcode,
+		Address: addr,
+	}
+
+	if f.Policy == PolicyLog {
+		if c.OnFault != nil {
+			c.OnFault(f)
+		}
+
+		return true
+	}
+
+	c.fault = f
+
+	return false
+}
 
+// takeFault returns and clears the fault which stopped the machine, moving the PC back
+// to the faulting instruction.
+func (c *CPU) takeFault() error {
+	if c.fault == nil {
+		return nil
+	}
+
+	f := c.fault
+	c.fault = nil
+	c.PC = f.PC
+
+	return f
+}
+
+// checkWrite returns true if the program can write the n bytes from the address, raising
+// a fault at the first one in the protected area below the program or past the end of memory.
+func (c *CPU) checkWrite(addr uint16, n int) bool {
+	for i := range n {
+		a := addr + uint16(i)
+		if a < c.ProgramAddress || int(a) >= c.Memory.Size {
+			return c.Raise(FaultProtectedWrite, a)
+		}
+	}
+
+	return true
+}
+
+// Fetch gets the next opcode and updates the PC.
+func (c *CPU) Fetch() Opcode {
 	rawOpcode := uint16(0)
-	rawOpcode += uint16(c.Memory.Read(c.PC)) << 0x08
-	rawOpcode += uint16(c.Memory.Read(c.PC + 1))
+	rawOpcode += uint16(c.Memory.Peek(c.PC)) << 0x08
+	rawOpcode += uint16(c.Memory.Peek(c.PC + 1))
 	c.PC += 2
 
 	return NewOpcode(rawOpcode)
 }
+
+// Skip advances the PC past the next instruction, which is 4 bytes for the XO-CHIP long index load.
+func (c *CPU) Skip() {
+	if c.Variant == VariantXOCHIP && c.Memory.Peek(c.PC) == 0xF0 && c.Memory.Peek(c.PC+1) == 0x00 {
+		c.PC += 4
+		return
+	}
+
+	c.PC += 2
+}
+
+// Tone returns the tone which should currently be played.
+func (c *CPU) Tone() Tone {
+	tone := Tone{
+		Active: c.SoundTimer.GetValue() > 0,
+	}
+
+	if c.HasAudioPattern {
+		tone.Pattern = &c.AudioPattern
+		tone.Rate = PatternRate(c.Pitch)
+	}
+
+	return tone
+}
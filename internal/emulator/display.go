@@ -1,76 +1,157 @@
 package emulator
 
 // Display represents the emulator display.
+// Each byte of the buffer is a pixel holding a bitmask of the bitplanes it is set in.
 type Display struct {
+	Config         *DisplayConfig
 	Width          int
 	Height         int
 	Buffer         []byte
 	LastTimerValue int
 	Timer          *Timer
-	Window         *Window
+	Renderer       Renderer
 	Changed        bool
+	// HighRes is true if the display is in high-resolution mode.
+	HighRes bool
+	// Frame is the number of display refreshes so far.
+	Frame int
+	// Plane is the bitmask of the bitplanes selected for drawing, clearing and scrolling.
+	Plane byte
 }
 
 // NewDisplay returns a new Display.
-func NewDisplay(config *DisplayConfig, win *Window) *Display {
+func NewDisplay(config *DisplayConfig, renderer Renderer) *Display {
 	return &Display{
+		Config:         config,
 		Width:          config.Width,
 		Height:         config.Height,
 		Buffer:         make([]byte, config.Width*config.Height),
 		LastTimerValue: 0,
 		Timer:          NewTimer(config.Frequency),
-		Window:         win,
+		Renderer:       renderer,
 		Changed:        false,
+		HighRes:        false,
+		Frame:          0,
+		Plane:          0x01,
 	}
 }
 
 // Tick calls the timer tick and if it has changed performs a display render.
 func (d *Display) Tick(delta int64) {
+	if d.Advance(delta) && d.Changed {
+		d.Changed = false
+		d.Renderer.Render(d.Buffer, d.Width, d.Height)
+	}
+}
+
+// Advance calls the timer tick and returns true if a new frame has started, without rendering.
+func (d *Display) Advance(delta int64) bool {
 	d.Timer.Tick(delta)
 
-	if d.LastTimerValue != d.Timer.GetValue() {
-		d.LastTimerValue = d.Timer.GetValue()
-		if d.Changed {
-			d.Changed = false
-			d.Window.Render(d.Buffer)
-		}
+	if d.LastTimerValue == d.Timer.GetValue() {
+		return false
 	}
+
+	d.LastTimerValue = d.Timer.GetValue()
+	d.Frame++
+
+	return true
 }
 
-// Clear the buffer.
+// Clear the selected planes of the buffer.
 func (d *Display) Clear() {
+	for i := range d.Buffer {
+		d.Buffer[i] &^= d.Plane
+	}
+
+	d.Changed = true
+}
+
+// SelectPlanes sets the bitmask of the bitplanes to draw to, ignoring unsupported planes.
+func (d *Display) SelectPlanes(mask byte) {
+	d.Plane = mask & byte((1<<d.Config.Planes)-1)
+}
+
+// PlaneCount returns the number of selected bitplanes.
+func (d *Display) PlaneCount() int {
+	count := 0
+
+	for p := 0; p < 8; p++ {
+		if GetBitAtPosition(d.Plane, p) > 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+// SetHighRes switches between low and high-resolution mode, resizing and clearing the buffer.
+// It does nothing if high-resolution mode is not supported.
+func (d *Display) SetHighRes(hires bool) {
+	if d.Config.HighResWidth == 0 || d.Config.HighResHeight == 0 {
+		return
+	}
+
+	d.HighRes = hires
+
+	if hires {
+		d.Width = d.Config.HighResWidth
+		d.Height = d.Config.HighResHeight
+	} else {
+		d.Width = d.Config.Width
+		d.Height = d.Config.Height
+	}
+
 	d.Buffer = make([]byte, d.Width*d.Height)
 	d.Changed = true
 }
 
 // Write the bytes to a location in the buffer.
-func (d *Display) Write(x int, y int, data []byte) bool {
+// The width is the sprite width in pixels, a multiple of 8. The data holds one sprite
+// for each selected plane, in order from the lowest plane. If clip is false then pixels
+// past the edges wrap around to the other side.
+func (d *Display) Write(x int, y int, data []byte, width int, clip bool) bool {
 	mx := x % d.Width
 	my := y % d.Height
 
+	planes := d.PlaneCount()
+	if planes == 0 {
+		return false
+	}
+
+	rowSize := width / 8
+	rows := len(data) / rowSize / planes
 	unset := false
+	offset := 0
 
-	for i := 0; i < len(data); i++ {
-		if (my + i) >= d.Height {
-			break
+	for p := 0; p < 8; p++ {
+		plane := byte(0x01 << p)
+		if d.Plane&plane == 0 {
+			continue
 		}
 
-		for j := 0; j < 8; j++ {
-			if (mx + j) >= d.Width {
+		sprite := data[offset : offset+rows*rowSize]
+		offset += rows * rowSize
+
+		for i := 0; i < rows; i++ {
+			if clip && (my+i) >= d.Height {
 				break
 			}
 
-			bit := GetBitAtPosition(data[i], 7-j)
-			if bit > 0 {
-				idx := (my+i)*d.Width + mx + j
+			for j := 0; j < width; j++ {
+				if clip && (mx+j) >= d.Width {
+					break
+				}
+
+				bit := GetBitAtPosition(sprite[i*rowSize+j/8], 7-j%8)
+				if bit > 0 {
+					idx := ((my+i)%d.Height)*d.Width + (mx+j)%d.Width
 
-				curr := d.Buffer[idx]
+					if d.Buffer[idx]&plane != 0 {
+						unset = true
+					}
 
-				if curr == 0 {
-					d.Buffer[idx] = 0xFF
-				} else {
-					unset = true
-					d.Buffer[idx] = 0x00
+					d.Buffer[idx] ^= plane
 				}
 			}
 		}
@@ -80,3 +161,46 @@ func (d *Display) Write(x int, y int, data []byte) bool {
 
 	return unset
 }
+
+// ScrollDown scrolls the buffer down by n pixels.
+func (d *Display) ScrollDown(n int) {
+	d.scroll(0, n)
+}
+
+// ScrollUp scrolls the buffer up by n pixels.
+func (d *Display) ScrollUp(n int) {
+	d.scroll(0, -n)
+}
+
+// ScrollLeft scrolls the buffer left by n pixels.
+func (d *Display) ScrollLeft(n int) {
+	d.scroll(-n, 0)
+}
+
+// ScrollRight scrolls the buffer right by n pixels.
+func (d *Display) ScrollRight(n int) {
+	d.scroll(n, 0)
+}
+
+// scroll moves the selected planes, leaving the other planes in place.
+func (d *Display) scroll(dx, dy int) {
+	buffer := make([]byte, d.Width*d.Height)
+
+	for y := 0; y < d.Height; y++ {
+		for x := 0; x < d.Width; x++ {
+			idx := y*d.Width + x
+			buffer[idx] = d.Buffer[idx] &^ d.Plane
+
+			sx := x - dx
+			sy := y - dy
+			if sx < 0 || sx >= d.Width || sy < 0 || sy >= d.Height {
+				continue
+			}
+
+			buffer[idx] |= d.Buffer[sy*d.Width+sx] & d.Plane
+		}
+	}
+
+	d.Buffer = buffer
+	d.Changed = true
+}
@@ -1,67 +1,388 @@
 package emulator
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
+const (
+	// ActionQuickSave saves the state to the quick state file.
+	ActionQuickSave = "quick-save"
+	// ActionQuickLoad loads the state from the quick state file.
+	ActionQuickLoad = "quick-load"
+	// ActionRewind scrubs backward while the hotkey is held.
+	ActionRewind = "rewind"
+	// ActionRewindStep pauses and steps backward one frame.
+	ActionRewindStep = "rewind-step"
+	// ActionPause pauses or resumes the emulation.
+	ActionPause = "pause"
+	// ActionScreenshot saves the display to a PNG file.
+	ActionScreenshot = "screenshot"
+	// ActionRecordGIF starts recording a GIF, or stops and saves the recording.
+	ActionRecordGIF = "record-gif"
+)
+
 // Emulator contains all of the systems for the emulator.
+// The frontend is the Display.Renderer, Input and Audio, which are set with options and
+// draw, read and play nothing by default.
 type Emulator struct {
 	Config  *Config
+	Program []byte
 	Memory  *Memory
 	CPU     *CPU
 	Display *Display
-	Window  *Window
+	Keypad  *Keypad
+	Input   InputSource
+	Audio   AudioSink
+	// Clock drives the main loop, a WallClock unless replaced.
+	Clock Clock
+	// QuickStateFile is the file used by the quick-save and quick-load actions.
+	QuickStateFile string
+	// CaptureName is the start of the screenshot and GIF file names, which end in the frame number.
+	CaptureName string
+	// GIF is the GIF being recorded, nil if none.
+	GIF *GIFRecorder
+	// Movie is the movie being recorded or played back, nil if none.
+	Movie *MovieSession
+	// Rewind holds the snapshots for rewinding, nil if rewinding is disabled.
+	Rewind *Rewind
+	// Paused stops the CPU and timers while the display and input keep running.
+	Paused bool
+	// Scrubbing is the time in nanoseconds left to keep rewinding after the last rewind keystroke.
+	Scrubbing   int64
+	rewindTimer *Timer
 }
 
-func New(fontFile, programFile string) (*Emulator, error) {
-	font, err := LoadFile(fontFile)
+// Option sets part of the frontend of a new Emulator.
+type Option func(e *Emulator)
+
+// WithRenderer draws the display with the renderer.
+func WithRenderer(r Renderer) Option {
+	return func(e *Emulator) {
+		e.Display.Renderer = r
+	}
+}
+
+// WithInput reads the keypad and hotkeys from the input source.
+func WithInput(i InputSource) Option {
+	return func(e *Emulator) {
+		e.Input = i
+	}
+}
+
+// WithAudio plays the sound through the sink.
+func WithAudio(a AudioSink) Option {
+	return func(e *Emulator) {
+		e.Audio = a
+	}
+}
+
+// New returns a new Emulator running the program file with the config.
+// If fontFile is empty then the built-in font is used.
+func New(config *Config, programFile, fontFile string, options ...Option) (*Emulator, error) {
+	font := DefaultFont
+	if fontFile != "" {
+		f, err := LoadFont(fontFile)
+		if err != nil {
+			return nil, err
+		}
+
+		font = f
+	}
+
+	program, err := LoadFile(programFile)
 	if err != nil {
 		return nil, err
 	}
 
-	program, err := LoadFile(programFile)
+	e, err := newEmulator(config, program, font, options)
+	if err != nil {
+		return nil, err
+	}
+
+	e.QuickStateFile = programFile + ".state"
+	e.CaptureName = strings.TrimSuffix(programFile, filepath.Ext(programFile))
+
+	return e, nil
+}
+
+// NewFromProgram returns a new Emulator running the program with the config and the
+// built-in font. The quick state and capture files are named for "program".
+func NewFromProgram(config *Config, program []byte, options ...Option) (*Emulator, error) {
+	e, err := newEmulator(config, program, DefaultFont, options)
 	if err != nil {
 		return nil, err
 	}
 
-	config := CHIP8Config
+	e.QuickStateFile = "program.state"
+	e.CaptureName = "program"
 
-	w := NewWindow()
-	d := NewDisplay(config.Display, w)
+	return e, nil
+}
+
+func newEmulator(config *Config, program, font []byte, options []Option) (*Emulator, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	d := NewDisplay(config.Display, NullRenderer{})
 	m := NewMemory(config.Memory.Size)
+	k := NewKeypad()
 
 	e := &Emulator{
 		Config:  config,
+		Program: program,
 		Memory:  m,
-		CPU:     NewCPU(config.CPU, config.Memory.ProgramAddress, m, d),
+		CPU:     NewCPU(config, m, d, k),
 		Display: d,
-		Window:  w,
+		Keypad:  k,
+		Input:   NullInput{},
+		Audio:   &NullSink{},
+		Clock:   NewWallClock(int64(time.Microsecond)),
+
+		rewindTimer: NewTimer(config.Display.Frequency),
+	}
+
+	for _, option := range options {
+		option(e)
+	}
+
+	if config.Rewind.Budget > 0 {
+		e.Rewind = NewRewind(config.Rewind.Budget)
+	}
+
+	e.CPU.OnFault = func(f *Fault) {
+		e.SetStatus(f.Error())
 	}
 
 	e.Memory.Write(config.Memory.FontAddress, font)
+	if config.Variant != VariantCHIP8 {
+		e.Memory.Write(config.Memory.BigFontAddress, DefaultBigFont)
+	}
 	e.Memory.Write(config.Memory.ProgramAddress, program)
 
 	return e, nil
 }
 
-func (e *Emulator) Start() {
-	e.Window.Init()
-	defer e.Window.Destroy()
+func (e *Emulator) Start() error {
+	for _, f := range e.frontends() {
+		if err := f.Init(); err != nil {
+			return err
+		}
+		defer f.Destroy()
+	}
+	defer e.Audio.Close()
+	defer e.stopGIF()
+
+	e.Clock.Tick()
+
+	for !e.Input.ShouldExit() && !e.CPU.Exited {
+		delta := e.Clock.Tick()
+
+		keypad := e.Keypad
+		if e.Movie != nil {
+			keypad = e.Movie.Live
+		}
+
+		e.Input.Update(delta, keypad)
+		for _, action := range e.Input.TakeActions() {
+			e.HandleAction(action)
+		}
+		if err := e.Advance(delta); err != nil {
+			return err
+		}
 
-	now := time.Now().UnixNano()
-	delta := int64(0)
+		if e.Movie != nil && e.Movie.Err != nil {
+			return e.Movie.Err
+		}
 
-	for !e.Window.ShouldExit() {
-		delta = time.Now().UnixNano() - now
+		e.Clock.Wait()
+	}
+
+	return nil
+}
 
-		e.CPU.Tick(delta)
+// Advance runs the emulator for delta nanoseconds, recording a rewind snapshot at the
+// start of each frame. While scrubbing it steps backward a frame per display period instead.
+// It returns the fault which stopped the machine, if any.
+func (e *Emulator) Advance(delta int64) error {
+	if e.Scrubbing > 0 {
+		e.Scrubbing -= delta
+		if e.rewindTimer.Tick(delta) {
+			e.StepBack()
+		}
 		e.Display.Tick(delta)
 
-		now = time.Now().UnixNano()
-		// TODO: use the delta to reduce the sleep
-		time.Sleep(1 * time.Microsecond)
+		return nil
 	}
+
+	if e.Paused {
+		e.Display.Tick(delta)
+
+		return nil
+	}
+
+	frame := e.Display.Frame
+	if err := e.CPU.Tick(delta); err != nil {
+		return err
+	}
+	e.Display.Tick(delta)
+	e.Audio.Update(delta, e.CPU.Tone())
+
+	if e.Display.Frame != frame {
+		e.startFrame()
+	}
+
+	return nil
+}
+
+// startFrame updates the movie, GIF recording and rewind buffer at the start of a frame.
+func (e *Emulator) startFrame() {
+	if e.Movie != nil {
+		e.Movie.Frame(e)
+	}
+
+	if e.GIF != nil {
+		e.GIF.Frame(e.Display)
+		if e.GIF.Done() {
+			e.stopGIF()
+		}
+	}
+
+	if e.Rewind != nil {
+		e.Rewind.Record(e)
+	}
+}
+
+// StepBack restores the emulator to the previous frame in the rewind buffer.
+// It returns false if rewinding is disabled or there is nothing to step back to.
+func (e *Emulator) StepBack() bool {
+	if e.Rewind == nil || !e.Rewind.StepBack(e) {
+		return false
+	}
+
+	e.SetStatus(fmt.Sprintf("rewind: frame %d", e.Display.Frame))
+
+	return true
+}
+
+// HandleAction performs a hotkey action, showing the result in the window status.
+func (e *Emulator) HandleAction(action string) {
+	var err error
+	file := e.QuickStateFile
+
+	switch action {
+	case ActionQuickSave:
+		err = e.SaveStateFile(e.QuickStateFile)
+	case ActionQuickLoad:
+		if e.Movie != nil {
+			err = ErrMovieActive
+			break
+		}
+
+		err = e.LoadStateFile(e.QuickStateFile)
+		if err == nil && e.Rewind != nil {
+			e.Rewind.Clear()
+		}
+	case ActionRewind, ActionRewindStep:
+		if e.Movie != nil {
+			err = ErrMovieActive
+			break
+		}
+
+		if e.Rewind == nil {
+			err = fmt.Errorf("rewind is disabled")
+			break
+		}
+
+		if action == ActionRewind {
+			e.Scrubbing = int64(e.Config.Input.HoldTimeout) * int64(time.Millisecond)
+		} else {
+			e.Paused = true
+			e.StepBack()
+		}
+
+		e.Display.Changed = true
+
+		return
+	case ActionPause:
+		// The display keeps counting frames while paused, which the movie would not see.
+		if e.Movie != nil {
+			err = ErrMovieActive
+			break
+		}
+
+		e.Paused = !e.Paused
+		if e.Paused {
+			e.SetStatus("paused")
+		} else {
+			e.SetStatus("")
+		}
+
+		return
+	case ActionScreenshot:
+		file = fmt.Sprintf("%s-%06d.png", e.CaptureName, e.Display.Frame)
+		err = e.Display.SaveScreenshot(file, e.Config.Capture.Scale)
+	case ActionRecordGIF:
+		if e.GIF != nil {
+			e.stopGIF()
+			return
+		}
+
+		e.GIF = NewGIFRecorder(e.Config, e.Display.Frame, e.Config.Capture.GIFLimit*e.Config.Display.Frequency)
+		e.SetStatus("recording gif")
+
+		return
+	default:
+		err = fmt.Errorf("unknown action %q", action)
+	}
+
+	if err != nil {
+		e.SetStatus(err.Error())
+	} else {
+		e.SetStatus(action + ": " + file)
+	}
+}
+
+// stopGIF stops the GIF recording, if any, and saves it to a file named for its first frame.
+func (e *Emulator) stopGIF() {
+	if e.GIF == nil {
+		return
+	}
+
+	file := fmt.Sprintf("%s-%06d.gif", e.CaptureName, e.GIF.Start)
+	if err := e.GIF.SaveFile(file); err != nil {
+		e.SetStatus(err.Error())
+	} else {
+		e.SetStatus(ActionRecordGIF + ": " + file)
+	}
+
+	e.GIF = nil
+}
+
+// SetStatus shows the message on the renderer with the next frame.
+func (e *Emulator) SetStatus(status string) {
+	e.Display.Renderer.SetStatus(status)
+	e.Display.Changed = true
+}
+
+// frontends returns the renderer and input source which need to be initialised,
+// once each if they are the same.
+func (e *Emulator) frontends() []Frontend {
+	frontends := []Frontend{}
+
+	if f, ok := e.Display.Renderer.(Frontend); ok {
+		frontends = append(frontends, f)
+	}
+
+	if f, ok := e.Input.(Frontend); ok && (len(frontends) == 0 || frontends[0] != f) {
+		frontends = append(frontends, f)
+	}
+
+	return frontends
 }
 
 func LoadFile(file string) ([]byte, error) {
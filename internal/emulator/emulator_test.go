@@ -0,0 +1,25 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/jamrig/chippy/internal/assembler"
+)
+
+// newTestEmulator returns an emulator with the config and frontend options running the
+// assembled Octo source.
+func newTestEmulator(tb testing.TB, config *Config, src string, options ...Option) *Emulator {
+	tb.Helper()
+
+	program, err := assembler.Assemble(src)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	e, err := NewFromProgram(config, program.ROM, options...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return e
+}
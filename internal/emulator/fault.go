@@ -0,0 +1,129 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FaultKind is a kind of machine fault.
+type FaultKind int
+
+const (
+	// FaultUnknownOpcode is raised when an opcode is not in the instruction set.
+	FaultUnknownOpcode FaultKind = iota
+	// FaultStackUnderflow is raised when 00EE returns with an empty stack.
+	FaultStackUnderflow
+	// FaultStackOverflow is raised when 2NNN calls with the stack at its limit.
+	FaultStackOverflow
+	// FaultPCOutOfBounds is raised when the PC runs past the end of memory. When it is
+	// only logged the PC wraps around to address 0.
+	FaultPCOutOfBounds
+	// FaultProtectedWrite is raised when the program writes to the font and interpreter
+	// area below the program address, or past the end of memory.
+	FaultProtectedWrite
+)
+
+var (
+	// ErrUnknownOpcode is the error of an unknown opcode fault.
+	ErrUnknownOpcode = errors.New("unknown opcode")
+	// ErrStackUnderflow is the error of a stack underflow fault.
+	ErrStackUnderflow = errors.New("stack underflow")
+	// ErrStackOverflow is the error of a stack overflow fault.
+	ErrStackOverflow = errors.New("stack overflow")
+	// ErrPCOutOfBounds is the error of a PC out of bounds fault.
+	ErrPCOutOfBounds = errors.New("pc out of bounds")
+	// ErrProtectedWrite is the error of a protected write fault.
+	ErrProtectedWrite = errors.New("write to protected memory")
+)
+
+// faultErrors are the errors of each kind of fault.
+var faultErrors = map[FaultKind]error{
+	FaultUnknownOpcode:  ErrUnknownOpcode,
+	FaultStackUnderflow: ErrStackUnderflow,
+	FaultStackOverflow:  ErrStackOverflow,
+	FaultPCOutOfBounds:  ErrPCOutOfBounds,
+	FaultProtectedWrite: ErrProtectedWrite,
+}
+
+// FaultPolicy is what happens when a fault is raised.
+type FaultPolicy int
+
+const (
+	// PolicyHalt stops the machine and returns the fault from Step, Tick and Start.
+	PolicyHalt FaultPolicy = iota
+	// PolicyLog reports the fault to CPU.OnFault and carries on.
+	PolicyLog
+	// PolicyBreak stops the machine like PolicyHalt, so the caller can open the debugger.
+	PolicyBreak
+)
+
+// ParseFaultPolicy returns the policy with the name.
+func ParseFaultPolicy(name string) (FaultPolicy, error) {
+	for _, p := range []FaultPolicy{PolicyHalt, PolicyLog, PolicyBreak} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown fault policy %q", name)
+}
+
+// String returns the name of the policy.
+func (p FaultPolicy) String() string {
+	switch p {
+	case PolicyHalt:
+		return "halt"
+	case PolicyLog:
+		return "log"
+	case PolicyBreak:
+		return "break"
+	}
+
+	return "unknown"
+}
+
+// MarshalText returns the name of the policy.
+func (p FaultPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText sets the policy from its name.
+func (p *FaultPolicy) UnmarshalText(text []byte) error {
+	policy, err := ParseFaultPolicy(string(text))
+	if err != nil {
+		return err
+	}
+
+	*p = policy
+
+	return nil
+}
+
+// Fault is a machine fault raised while executing an instruction.
+type Fault struct {
+	Kind   FaultKind
+	Policy FaultPolicy
+	// PC is the address of the faulting instruction.
+	PC uint16
+	// Opcode is the faulting opcode.
+	Opcode uint16
+	// Address is the memory address involved, for PC and protected write faults.
+	Address uint16
+}
+
+// Error returns a description of the fault.
+func (f *Fault) Error() string {
+	switch f.Kind {
+	case FaultPCOutOfBounds:
+		return fmt.Sprintf("%v: 0x%04X", f.Unwrap(), f.Address)
+	case FaultProtectedWrite:
+		return fmt.Sprintf("%v: 0x%04X by %04X at 0x%04X", f.Unwrap(), f.Address, f.Opcode, f.PC)
+	}
+
+	return fmt.Sprintf("%v: %04X at 0x%04X", f.Unwrap(), f.Opcode, f.PC)
+}
+
+// Unwrap returns the error of the fault kind, so faults can be matched with errors.Is.
+func (f *Fault) Unwrap() error {
+	return faultErrors[f.Kind]
+}
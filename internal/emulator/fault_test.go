@@ -0,0 +1,131 @@
+package emulator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaults(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *Config
+		src    string
+		// steps is the number of steps up to and including the faulting one.
+		steps int
+		kind  FaultKind
+		// pc and addr are the faulting instruction and the address reported.
+		pc   uint16
+		addr uint16
+		// logged checks the machine after the faulting step when the fault is only logged.
+		logged func(t *testing.T, e *Emulator)
+	}{
+		{
+			name: "unknown opcode", config: CHIP8Config, src: ": main 0x51 0x21",
+			steps: 1, kind: FaultUnknownOpcode, pc: 0x200,
+			logged: func(t *testing.T, e *Emulator) { assert.Equal(t, uint16(0x202), e.CPU.PC) },
+		},
+		{
+			name: "stack underflow", config: CHIP8Config, src: ": main return",
+			steps: 1, kind: FaultStackUnderflow, pc: 0x200,
+			logged: func(t *testing.T, e *Emulator) { assert.Equal(t, uint16(0), e.CPU.PC) },
+		},
+		{
+			name: "stack overflow", config: CHIP8Config, src: ": main main",
+			steps: 17, kind: FaultStackOverflow, pc: 0x200,
+			logged: func(t *testing.T, e *Emulator) { assert.Equal(t, 17, e.CPU.Stack.Count) },
+		},
+		{
+			name: "pc out of bounds", config: CHIP8Config, src: ": main jump 0xFFE :org 0xFFE clear",
+			steps: 3, kind: FaultPCOutOfBounds, pc: 0x1000, addr: 0x1000,
+			logged: func(t *testing.T, e *Emulator) {
+				// The PC wraps to 0 and runs the first instruction there.
+				assert.Equal(t, uint16(2), e.CPU.PC)
+			},
+		},
+		{
+			name: "save below program", config: CHIP8Config, src: ": main i := 0x100 save v0",
+			steps: 2, kind: FaultProtectedWrite, pc: 0x202, addr: 0x100,
+			logged: func(t *testing.T, e *Emulator) { assert.Equal(t, uint16(0x204), e.CPU.PC) },
+		},
+		{
+			name: "save past memory", config: CHIP8Config, src: ": main v2 := 9 i := 0xFFE save v2",
+			steps: 3, kind: FaultProtectedWrite, pc: 0x204, addr: 0x1000,
+			logged: func(t *testing.T, e *Emulator) { assert.Equal(t, byte(0), e.Memory.Data[0xFFE]) },
+		},
+		{
+			name: "bcd past memory", config: CHIP8Config, src: ": main v0 := 123 i := 0xFFE bcd v0",
+			steps: 3, kind: FaultProtectedWrite, pc: 0x204, addr: 0x1000,
+			logged: func(t *testing.T, e *Emulator) {
+				assert.Equal(t, []byte{1, 2}, e.Memory.Data[0xFFE:])
+			},
+		},
+		{
+			name: "range save wraps", config: XOCHIPConfig, src: ": main i := long 0xFFFF save v0 - v1",
+			steps: 2, kind: FaultProtectedWrite, pc: 0x204, addr: 0x0000,
+			logged: func(t *testing.T, e *Emulator) { assert.Equal(t, uint16(0x206), e.CPU.PC) },
+		},
+	}
+
+	for _, c := range cases {
+		for _, policy := range []FaultPolicy{PolicyHalt, PolicyLog, PolicyBreak} {
+			t.Run(c.name+" "+policy.String(), func(t *testing.T) {
+				config := c.config.Clone()
+				config.CPU.Faults = FaultConfig{
+					UnknownOpcode:  policy,
+					StackUnderflow: policy,
+					StackOverflow:  policy,
+					PCOutOfBounds:  policy,
+					ProtectedWrite: policy,
+				}
+
+				e := newTestEmulator(t, config, c.src)
+
+				faults := []*Fault{}
+				e.CPU.OnFault = func(f *Fault) { faults = append(faults, f) }
+
+				var err error
+				for range c.steps {
+					if err = e.CPU.Step(); err != nil {
+						break
+					}
+				}
+
+				var f *Fault
+				if policy == PolicyLog {
+					assert.NoError(t, err)
+					if !assert.NotEmpty(t, faults) {
+						return
+					}
+					f = faults[0]
+					c.logged(t, e)
+				} else {
+					assert.Empty(t, faults)
+					if !assert.True(t, errors.As(err, &f), "got %v", err) {
+						return
+					}
+					assert.Equal(t, c.pc, e.CPU.PC, "PC is left on the instruction")
+				}
+
+				assert.Equal(t, c.kind, f.Kind)
+				assert.Equal(t, policy, f.Policy)
+				assert.Equal(t, c.pc, f.PC)
+				assert.Equal(t, c.addr, f.Address)
+				assert.True(t, errors.Is(f, faultErrors[c.kind]))
+			})
+		}
+	}
+}
+
+func TestProtectedWriteHaltKeepsMemory(t *testing.T) {
+	config := CHIP8Config.Clone()
+	config.CPU.Faults.ProtectedWrite = PolicyHalt
+
+	e := newTestEmulator(t, config, ": main v0 := 7 i := 0x1FF save v1")
+	before := append([]byte{}, e.Memory.Data...)
+
+	_, err := e.RunHeadless(HeadlessLimits{Instructions: 3})
+	assert.True(t, errors.Is(err, ErrProtectedWrite), "got %v", err)
+	assert.Equal(t, before, e.Memory.Data)
+}
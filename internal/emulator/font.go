@@ -0,0 +1,69 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+// FontCharacterSize is the size of a single font character in bytes.
+const FontCharacterSize = 5
+
+// FontSize is the size of the font data in bytes.
+const FontSize = 16 * FontCharacterSize
+
+// DefaultFont is the standard hexadecimal font (0-F), 4x5 pixels per character.
+var DefaultFont = []byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+// LoadFont loads a font file, checking it has the layout of 16 characters of 5 bytes
+// with only the upper 4 bits of each byte used.
+func LoadFont(file string) ([]byte, error) {
+	font, err := LoadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(font) != FontSize {
+		return nil, fmt.Errorf("font %s must be %d bytes, got %d", file, FontSize, len(font))
+	}
+
+	for i, b := range font {
+		if b&0x0F != 0 {
+			return nil, fmt.Errorf("font %s character %X uses more than 4 pixels of width", file, i/FontCharacterSize)
+		}
+	}
+
+	return font, nil
+}
+
+// BigFontCharacterSize is the size of a single large font character in bytes.
+const BigFontCharacterSize = 10
+
+// DefaultBigFont is the SUPER-CHIP large decimal font (0-9), 8x10 pixels per character.
+var DefaultBigFont = []byte{
+	0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+	0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+	0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+	0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+	0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+	0x3E, 0x7C, 0xC0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+	0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+	0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+}
@@ -0,0 +1,109 @@
+package emulator
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+)
+
+// GIFRecorder records the display into an animated GIF, one display frame at a time. The
+// frame delays add up to the display timing, and unchanged frames are merged into one image.
+type GIFRecorder struct {
+	// Scale is the number of image pixels per low-resolution display pixel.
+	Scale int
+	// Palette is the colours of the pixels, indexed by their bitplanes.
+	Palette color.Palette
+	// Frequency is the number of display frames per second.
+	Frequency int
+	// Start is the first display frame recorded.
+	Start int
+	// Limit is the most display frames recorded, 0 for no limit.
+	Limit int
+	// Frames is the number of display frames recorded.
+	Frames int
+	// GIF is the recording so far.
+	GIF *gif.GIF
+	// last is the buffer of the last image, to merge unchanged frames.
+	last []byte
+	// elapsed is the total of the frame delays in hundredths of a second.
+	elapsed int
+}
+
+// NewGIFRecorder returns a new GIFRecorder for the config, recording up to limit display
+// frames from the start frame.
+func NewGIFRecorder(config *Config, start, limit int) *GIFRecorder {
+	return &GIFRecorder{
+		Scale:     config.Capture.Scale,
+		Palette:   config.Display.Palette(),
+		Frequency: config.Display.Frequency,
+		Start:     start,
+		Limit:     limit,
+		GIF:       &gif.GIF{},
+	}
+}
+
+// Frame records the display as shown for one display frame, and should be called at the
+// start of each frame.
+//
+// GIF delays are in hundredths of a second, so at 60 Hz each frame is shown for 1 or 2.
+// Viewers slow down images shown for less than 2, so a changed frame replaces the last
+// image rather than following it until that image has been shown for 2.
+func (r *GIFRecorder) Frame(d *Display) {
+	if d.Frame < r.Start || r.Done() {
+		return
+	}
+
+	n := len(r.GIF.Image)
+	if n == 0 || !bytes.Equal(r.last, d.Buffer) {
+		img := NewFrameImage(d.Buffer, d.Width, d.Height, captureScale(r.Scale, d.Config.Width, d.Width), r.Palette)
+
+		if n > 0 && r.GIF.Delay[n-1] < 2 && img.Bounds() == r.GIF.Image[n-1].Bounds() {
+			r.GIF.Image[n-1] = img
+		} else {
+			r.GIF.Image = append(r.GIF.Image, img)
+			r.GIF.Delay = append(r.GIF.Delay, 0)
+		}
+
+		r.last = append(r.last[:0], d.Buffer...)
+	}
+
+	r.Frames++
+
+	elapsed := (r.Frames*100 + r.Frequency/2) / r.Frequency
+	r.GIF.Delay[len(r.GIF.Delay)-1] += elapsed - r.elapsed
+	r.elapsed = elapsed
+}
+
+// Done returns true once the limit has been recorded.
+func (r *GIFRecorder) Done() bool {
+	return r.Limit > 0 && r.Frames >= r.Limit
+}
+
+// Write writes the recording as a GIF which loops forever.
+func (r *GIFRecorder) Write(w io.Writer) error {
+	bounds := image.Rectangle{}
+	for _, img := range r.GIF.Image {
+		bounds = bounds.Union(img.Bounds())
+	}
+
+	r.GIF.Config = image.Config{
+		ColorModel: r.Palette,
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+	}
+
+	return gif.EncodeAll(w, r.GIF)
+}
+
+// SaveFile writes the recording to the file.
+func (r *GIFRecorder) SaveFile(file string) error {
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,65 @@
+package emulator
+
+import (
+	"bytes"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGIFRecorderTiming(t *testing.T) {
+	config := CHIP8Config.Clone()
+	d := NewDisplay(config.Display, NullRenderer{})
+	r := NewGIFRecorder(config, 10, 120)
+
+	// Before the start frame nothing is recorded.
+	for d.Frame = 0; d.Frame < 10; d.Frame++ {
+		r.Frame(d)
+	}
+	assert.Equal(t, 0, r.Frames)
+
+	// A pixel moves every frame for a second, then the display holds for a second.
+	for ; !r.Done(); d.Frame++ {
+		if d.Frame < 70 {
+			clear(d.Buffer)
+			d.Buffer[d.Frame] = 1
+		}
+		r.Frame(d)
+	}
+
+	total := 0
+	for _, delay := range r.GIF.Delay {
+		assert.GreaterOrEqual(t, delay, 2)
+		total += delay
+	}
+	assert.Equal(t, 200, total)
+	assert.Equal(t, 120, r.Frames)
+	// The held display is a single image.
+	assert.GreaterOrEqual(t, r.GIF.Delay[len(r.GIF.Delay)-1], 100)
+
+	buf := &bytes.Buffer{}
+	if !assert.NoError(t, r.Write(buf)) {
+		return
+	}
+
+	g, err := gif.DecodeAll(buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, len(r.GIF.Image), len(g.Image))
+		assert.Equal(t, 64*4, g.Config.Width)
+		assert.Equal(t, 32*4, g.Config.Height)
+	}
+}
+
+func TestDisplayImageScale(t *testing.T) {
+	config := SCHIPConfig.Clone()
+	d := NewDisplay(config.Display, NullRenderer{})
+
+	assert.Equal(t, 64*4, d.Image(4).Bounds().Dx())
+
+	// High resolution images are the same size, with half the scale.
+	d.Width, d.Height = 128, 64
+	d.Buffer = make([]byte, d.Width*d.Height)
+	assert.Equal(t, 64*4, d.Image(4).Bounds().Dx())
+	assert.Equal(t, 128, d.Image(1).Bounds().Dx())
+}
@@ -0,0 +1,200 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GraphicsQuery asks the terminal whether it supports the Kitty graphics protocol, for the
+// size of the text area in pixels and for its primary device attributes. Every terminal
+// answers the last, so its reply ends the query.
+const GraphicsQuery = "\033_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\033\\\033[14t\033[c"
+
+// kittyChunkSize is the most base64 payload sent in each Kitty graphics escape sequence.
+const kittyChunkSize = 4096
+
+var (
+	kittyReply      = regexp.MustCompile(`\x1b_Gi=31;OK\x1b\\`)
+	pixelSizeReply  = regexp.MustCompile(`\x1b\[4;(\d+);(\d+)t`)
+	attributesReply = regexp.MustCompile(`\x1b\[\?([\d;]*)c`)
+)
+
+// GraphicsSupport is what a terminal reported in reply to GraphicsQuery.
+type GraphicsSupport struct {
+	// Kitty is true if the Kitty graphics protocol is supported.
+	Kitty bool
+	// Sixel is true if Sixel graphics are supported.
+	Sixel bool
+	// PixelWidth and PixelHeight are the size of the text area in pixels, 0 if unknown.
+	PixelWidth  int
+	PixelHeight int
+}
+
+// ParseGraphicsReply returns the graphics support from the reply to GraphicsQuery.
+func ParseGraphicsReply(reply []byte) GraphicsSupport {
+	g := GraphicsSupport{
+		Kitty: kittyReply.Match(reply),
+	}
+
+	if m := pixelSizeReply.FindSubmatch(reply); m != nil {
+		g.PixelHeight, _ = strconv.Atoi(string(m[1]))
+		g.PixelWidth, _ = strconv.Atoi(string(m[2]))
+	}
+
+	if m := attributesReply.FindSubmatch(reply); m != nil {
+		for _, attr := range strings.Split(string(m[1]), ";") {
+			if attr == "4" {
+				g.Sixel = true
+			}
+		}
+	}
+
+	return g
+}
+
+// graphicsReplyDone returns true once the reply to GraphicsQuery is complete.
+func graphicsReplyDone(reply []byte) bool {
+	return attributesReply.Match(reply)
+}
+
+// NewFrameImage returns the buffer as an image with each pixel repeated scale times across
+// and down. The bitplanes set in a pixel are its index in the palette.
+func NewFrameImage(buffer []byte, width, height, scale int, palette color.Palette) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), palette)
+	last := byte(len(palette) - 1)
+
+	for y := 0; y < height*scale; y++ {
+		row := img.Pix[y*img.Stride:]
+		src := buffer[(y/scale)*width:]
+		for x := 0; x < width*scale; x++ {
+			row[x] = min(src[x/scale], last)
+		}
+	}
+
+	return img
+}
+
+// EncodeKitty writes the image as PNG data with the Kitty graphics protocol, displayed at the
+// cursor without moving it. The image replaces any earlier one with the same id.
+func EncodeKitty(w io.Writer, img image.Image, id int) error {
+	buf := &bytes.Buffer{}
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(buf, img); err != nil {
+		return err
+	}
+
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+	bw := bufio.NewWriter(w)
+
+	for i := 0; i < len(payload); i += kittyChunkSize {
+		end := min(i+kittyChunkSize, len(payload))
+
+		more := 0
+		if end < len(payload) {
+			more = 1
+		}
+
+		if i == 0 {
+			fmt.Fprintf(bw, "\033_Ga=T,f=100,i=%d,p=1,q=2,C=1,m=%d;", id, more)
+		} else {
+			fmt.Fprintf(bw, "\033_Gm=%d;", more)
+		}
+		bw.WriteString(payload[i:end])
+		bw.WriteString("\033\\")
+	}
+
+	return bw.Flush()
+}
+
+// EncodeSixel writes the image as Sixel graphics, with a colour register for each palette
+// colour. Each band of six rows is written once per colour it uses.
+func EncodeSixel(w io.Writer, img *image.Paletted) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\033Pq\"1;1;%d;%d", width, height)
+
+	for i, c := range img.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, sixelPercent(r), sixelPercent(g), sixelPercent(b))
+	}
+
+	sixels := make([]byte, width)
+	for y := 0; y < height; y += 6 {
+		if y > 0 {
+			bw.WriteByte('-')
+		}
+
+		first := true
+		for i := range img.Palette {
+			used := false
+			for x := range sixels {
+				sixels[x] = 0
+				for dy := 0; dy < 6 && y+dy < height; dy++ {
+					if img.Pix[(y+dy)*img.Stride+x] == uint8(i) {
+						sixels[x] |= 1 << dy
+						used = true
+					}
+				}
+			}
+
+			if !used {
+				continue
+			}
+
+			if !first {
+				bw.WriteByte('$')
+			}
+			first = false
+
+			fmt.Fprintf(bw, "#%d", i)
+			writeSixels(bw, sixels)
+		}
+	}
+
+	bw.WriteString("\033\\")
+
+	return bw.Flush()
+}
+
+// writeSixels writes the sixels of a band, with runs of four or more repeated, and without
+// the trailing empty sixels.
+func writeSixels(bw *bufio.Writer, sixels []byte) {
+	end := len(sixels)
+	for end > 0 && sixels[end-1] == 0 {
+		end--
+	}
+
+	for i := 0; i < end; {
+		j := i + 1
+		for j < end && sixels[j] == sixels[i] {
+			j++
+		}
+
+		c := 0x3F + sixels[i]
+		if n := j - i; n >= 4 {
+			fmt.Fprintf(bw, "!%d%c", n, c)
+		} else {
+			for ; n > 0; n-- {
+				bw.WriteByte(c)
+			}
+		}
+
+		i = j
+	}
+}
+
+// sixelPercent returns a 16-bit colour component as the 0-100 scale of Sixel colours.
+func sixelPercent(v uint32) uint32 {
+	return (v*100 + 0x7FFF) / 0xFFFF
+}
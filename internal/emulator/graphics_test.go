@@ -0,0 +1,87 @@
+package emulator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// graphicsFrame returns a small frame with a border, a filled block and a diagonal in the
+// XO-CHIP plane colours, so the encoders see runs, single pixels and bands with several colours.
+func graphicsFrame() ([]byte, int, int) {
+	width, height := 16, 8
+	buffer := make([]byte, width*height)
+
+	for x := 0; x < width; x++ {
+		buffer[x] = 1
+		buffer[(height-1)*width+x] = 1
+	}
+	for y := 2; y < 5; y++ {
+		for x := 2; x < 6; x++ {
+			buffer[y*width+x] = 1
+		}
+		buffer[y*width+8+y] = byte(y - 1)
+	}
+
+	return buffer, width, height
+}
+
+// assertGolden compares the output with the golden file, rewriting it with -update.
+func assertGolden(t *testing.T, name string, got []byte) {
+	golden := filepath.Join("testdata", "golden", name)
+	if *update {
+		assert.NoError(t, os.WriteFile(golden, got, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("no golden file, check the output and rerun with -update: %v", err)
+	}
+
+	assert.Equal(t, want, got, "output does not match %s", golden)
+}
+
+func TestEncodeKitty(t *testing.T) {
+	buffer, width, height := graphicsFrame()
+	palette := (&DisplayConfig{Theme: "amber", Foreground: -1, Background: -1}).Palette()
+
+	got := &bytes.Buffer{}
+	assert.NoError(t, EncodeKitty(got, NewFrameImage(buffer, width, height, 2, palette), 1))
+	assertGolden(t, "frame.kitty", got.Bytes())
+}
+
+func TestEncodeSixel(t *testing.T) {
+	buffer, width, height := graphicsFrame()
+	palette := (&DisplayConfig{Theme: "amber", Foreground: -1, Background: -1}).Palette()
+
+	got := &bytes.Buffer{}
+	assert.NoError(t, EncodeSixel(got, NewFrameImage(buffer, width, height, 2, palette)))
+	assertGolden(t, "frame.sixel", got.Bytes())
+}
+
+func TestParseGraphicsReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  GraphicsSupport
+	}{
+		{"none", "\033[?1;2c", GraphicsSupport{}},
+		{"sixel", "\033[4;600;800t\033[?62;4;6;22c", GraphicsSupport{Sixel: true, PixelWidth: 800, PixelHeight: 600}},
+		{"kitty", "\033_Gi=31;OK\033\\\033[4;480;640t\033[?62;22c", GraphicsSupport{Kitty: true, PixelWidth: 640, PixelHeight: 480}},
+		{"kitty error", "\033_Gi=31;ENOTSUPPORTED\033\\\033[?62;22c", GraphicsSupport{}},
+		{"attribute 4 only", "\033[?64;14c", GraphicsSupport{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, graphicsReplyDone([]byte(tt.reply)))
+			assert.Equal(t, tt.want, ParseGraphicsReply([]byte(tt.reply)))
+		})
+	}
+
+	assert.False(t, graphicsReplyDone([]byte("\033_Gi=31;OK\033\\")))
+}
@@ -0,0 +1,90 @@
+package emulator
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoStopCondition is returned when a headless run has no condition to stop it.
+var ErrNoStopCondition = errors.New("headless run needs a frame, instruction, PC or time limit, a movie to play or a GIF length")
+
+// The reasons a headless run stopped.
+const (
+	StopFrames       = "frames"
+	StopInstructions = "instructions"
+	StopPC           = "pc"
+	StopTime         = "time"
+	StopExit         = "exit"
+	StopMovie        = "movie"
+	StopGIF          = "gif"
+)
+
+// HeadlessLimits are the conditions which stop a headless run. Zero or nil limits are not used.
+type HeadlessLimits struct {
+	// Frames is the number of display frames to run.
+	Frames int
+	// Instructions is the number of instructions to run.
+	Instructions uint64
+	// UntilPC stops the run once the program counter reaches the address.
+	UntilPC *uint16
+	// Time is the wall-clock time to run for.
+	Time time.Duration
+}
+
+// RunHeadless runs the emulator without a window until one of the limits is reached, the
+// program exits, a movie being played back ends or a GIF with a limit has been recorded,
+// and returns the reason it stopped.
+// The emulator is advanced in fixed steps of one instruction period, so the run does not
+// depend on the speed of the host, and the audio sink receives the emulated time.
+// A fault which stops the machine is returned as the error.
+func (e *Emulator) RunHeadless(limits HeadlessLimits) (string, error) {
+	playing := e.Movie != nil && !e.Movie.Recording
+	recording := e.GIF != nil && e.GIF.Limit > 0
+	if limits.Frames <= 0 && limits.Instructions == 0 && limits.UntilPC == nil && limits.Time <= 0 && !playing && !recording {
+		return "", ErrNoStopCondition
+	}
+
+	start := time.Now()
+	clock := NewVirtualClock(e.CPU.InstructionTimer.UpdateDelta)
+	frames := 0
+
+	if e.Movie != nil {
+		defer e.Movie.Stop(e)
+	}
+
+	for {
+		switch {
+		case e.Movie != nil && e.Movie.Err != nil:
+			return "", e.Movie.Err
+		case e.CPU.Exited:
+			return StopExit, nil
+		case playing && e.Movie.Done(e.Display.Frame):
+			return StopMovie, nil
+		case recording && e.GIF.Done():
+			return StopGIF, nil
+		case limits.Frames > 0 && frames >= limits.Frames:
+			return StopFrames, nil
+		case limits.Instructions > 0 && e.CPU.Cycles >= limits.Instructions:
+			return StopInstructions, nil
+		case limits.UntilPC != nil && e.CPU.PC == *limits.UntilPC:
+			return StopPC, nil
+		case limits.Time > 0 && time.Since(start) >= limits.Time:
+			return StopTime, nil
+		}
+
+		delta := clock.Tick()
+		if err := e.CPU.Tick(delta); err != nil {
+			return "", err
+		}
+		e.Audio.Update(delta, e.CPU.Tone())
+		if e.Display.Advance(delta) {
+			frames++
+			if e.Movie != nil {
+				e.Movie.Frame(e)
+			}
+			if e.GIF != nil {
+				e.GIF.Frame(e.Display)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomSource draws digits at random positions, waiting on the delay timer between them.
+const randomSource = `
+: main
+  loop
+    v0 := random 0x3F
+    v1 := random 0x1F
+    i := hex v2
+    sprite v0 v1 5
+    v2 += 1
+    v3 := 2
+    delay := v3
+    loop
+      v3 := delay
+      while v3 != 0
+    again
+  again`
+
+func TestHeadlessDeterministic(t *testing.T) {
+	for _, seed := range []int64{0, 1, -7} {
+		run := func() *Emulator {
+			config := CHIP8Config.Clone()
+			config.CPU.Seed = &seed
+
+			e := newTestEmulator(t, config, randomSource)
+			_, err := e.RunHeadless(HeadlessLimits{Frames: 120})
+			assert.NoError(t, err)
+
+			return e
+		}
+
+		a, b := run(), run()
+
+		assert.Equal(t, a.Display.Buffer, b.Display.Buffer, "seed %d display", seed)
+		assert.Equal(t, a.CPU.Rand.State, b.CPU.Rand.State, "seed %d rng", seed)
+		assert.Equal(t, a.Display.Frame, b.Display.Frame, "seed %d frame", seed)
+		assert.Contains(t, a.Display.Buffer, byte(1), "seed %d drew", seed)
+	}
+}
+
+func TestUnsetSeedIsNotZero(t *testing.T) {
+	seeded := NewRNG(0)
+
+	e := newTestEmulator(t, CHIP8Config.Clone(), randomSource)
+	assert.Nil(t, e.Config.CPU.Seed)
+	assert.NotEqual(t, seeded.State, e.CPU.Rand.State, "unset seeds from the time, not 0")
+}
@@ -1,144 +1,170 @@
 package emulator
 
-import "math/rand"
-
 // Instruction represents a CPU instruction.
 type Instruction struct {
 	Name    string
-	Is      func(o *Opcode) bool
-	Execute func(c *CPU, o *Opcode)
+	Is      func(o Opcode) bool
+	Execute func(c *CPU, o Opcode)
+}
+
+// InstructionSets contains the instruction set for each variant.
+var InstructionSets = map[Variant][]Instruction{
+	VariantCHIP8:  Instructions,
+	VariantSCHIP:  SCHIPInstructions,
+	VariantXOCHIP: XOCHIPInstructions,
 }
 
 // Instructions contains all of the available CPU instructions.
 var Instructions = []Instruction{
 	{
 		Name: "[00E0] Clear Screen",
-		Is:   func(o *Opcode) bool { return o.Raw == 0x00E0 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.Raw == 0x00E0 },
+		Execute: func(c *CPU, o Opcode) {
 			c.Display.Clear()
 		},
 	},
 	{
 		Name: "[00EE] Return Subroutine",
-		Is:   func(o *Opcode) bool { return o.Raw == 0x00EE },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.Raw == 0x00EE },
+		Execute: func(c *CPU, o Opcode) {
+			if c.Stack.Count == 0 && !c.Raise(FaultStackUnderflow, 0) {
+				return
+			}
+
 			c.PC = c.Stack.Pop()
 		},
 	},
 	{
 		Name: "[1NNN] Jump",
-		Is:   func(o *Opcode) bool { return o.F == 1 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 1 },
+		Execute: func(c *CPU, o Opcode) {
 			c.PC = o.NNN
 		},
 	},
 	{
 		Name: "[2NNN] Call Subroutine",
-		Is:   func(o *Opcode) bool { return o.F == 2 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 2 },
+		Execute: func(c *CPU, o Opcode) {
+			if c.Config.StackLimit > 0 && c.Stack.Count >= c.Config.StackLimit && !c.Raise(FaultStackOverflow, 0) {
+				return
+			}
+
 			c.Stack.Push(c.PC)
 			c.PC = o.NNN
 		},
 	},
 	{
 		Name: "[3XNN] Skip If VX == NN",
-		Is:   func(o *Opcode) bool { return o.F == 3 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 3 },
+		Execute: func(c *CPU, o Opcode) {
 			if c.V[o.X] == o.NN {
-				c.PC += 2
+				c.Skip()
 			}
 		},
 	},
 	{
 		Name: "[4XNN] Skip If VX != NN",
-		Is:   func(o *Opcode) bool { return o.F == 4 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 4 },
+		Execute: func(c *CPU, o Opcode) {
 			if c.V[o.X] != o.NN {
-				c.PC += 2
+				c.Skip()
 			}
 		},
 	},
 	{
 		Name: "[5XY0] Skip If VX == VY",
-		Is:   func(o *Opcode) bool { return o.F == 5 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 5 && o.N == 0 },
+		Execute: func(c *CPU, o Opcode) {
 			if c.V[o.X] == c.V[o.Y] {
-				c.PC += 2
+				c.Skip()
 			}
 		},
 	},
 	{
 		Name: "[6XNN] VX = NN",
-		Is:   func(o *Opcode) bool { return o.F == 6 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 6 },
+		Execute: func(c *CPU, o Opcode) {
 			c.V[o.X] = o.NN
 		},
 	},
 	{
 		Name: "[7XNN] Vx += NN (no carry)",
-		Is:   func(o *Opcode) bool { return o.F == 7 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 7 },
+		Execute: func(c *CPU, o Opcode) {
 			c.V[o.X] = c.V[o.X] + o.NN
 		},
 	},
 	{
 		Name: "[8XY0] Vx = Vy",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 0 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 0 },
+		Execute: func(c *CPU, o Opcode) {
 			c.V[o.X] = c.V[o.Y]
 		},
 	},
 	{
 		Name: "[8XY1] Vx |= Vy",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 1 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 1 },
+		Execute: func(c *CPU, o Opcode) {
 			c.V[o.X] |= c.V[o.Y]
+
+			if c.Config.InstructionResetFlagOnLogic {
+				c.V[15] = 0
+			}
 		},
 	},
 	{
 		Name: "[8XY2] Vx &= Vy",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 2 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 2 },
+		Execute: func(c *CPU, o Opcode) {
 			c.V[o.X] &= c.V[o.Y]
+
+			if c.Config.InstructionResetFlagOnLogic {
+				c.V[15] = 0
+			}
 		},
 	},
 	{
 		Name: "[8XY3] Vx ^= Vy",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 3 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 3 },
+		Execute: func(c *CPU, o Opcode) {
 			c.V[o.X] ^= c.V[o.Y]
+
+			if c.Config.InstructionResetFlagOnLogic {
+				c.V[15] = 0
+			}
 		},
 	},
 	{
 		Name: "[8XY4] Vx += Vy",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 4 },
-		Execute: func(c *CPU, o *Opcode) {
-			if int(c.V[o.X])+int(c.V[o.Y]) > 0xFFFF {
-				c.V[15] = 1
-			} else {
-				c.V[15] = 0
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 4 },
+		Execute: func(c *CPU, o Opcode) {
+			flag := byte(0)
+			if int(c.V[o.X])+int(c.V[o.Y]) > 0xFF {
+				flag = 1
 			}
 
+			// VF is set after the result, so the flag wins when X is F.
 			c.V[o.X] += c.V[o.Y]
+			c.V[15] = flag
 		},
 	},
 	{
 		Name: "[8XY5] Vx -= Vy",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 5 },
-		Execute: func(c *CPU, o *Opcode) {
-			if int(c.V[o.X])-int(c.V[o.Y]) > 0x0 {
-				c.V[15] = 1
-			} else {
-				c.V[15] = 0
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 5 },
+		Execute: func(c *CPU, o Opcode) {
+			flag := byte(0)
+			if c.V[o.X] >= c.V[o.Y] {
+				flag = 1
 			}
 
 			c.V[o.X] -= c.V[o.Y]
+			c.V[15] = flag
 		},
 	},
 	{
 		Name: "[8XY6] Vx >>= 1",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 6 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 6 },
+		Execute: func(c *CPU, o Opcode) {
 			VX := c.V[o.X]
 
 			if c.Config.InstructionAssignBeforeShift {
@@ -151,21 +177,21 @@ var Instructions = []Instruction{
 	},
 	{
 		Name: "[8XY7] Vx = Vy - Vx",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 7 },
-		Execute: func(c *CPU, o *Opcode) {
-			if int(c.V[o.Y])-int(c.V[o.X]) > 0x0 {
-				c.V[15] = 1
-			} else {
-				c.V[15] = 0
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 7 },
+		Execute: func(c *CPU, o Opcode) {
+			flag := byte(0)
+			if c.V[o.Y] >= c.V[o.X] {
+				flag = 1
 			}
 
 			c.V[o.X] = c.V[o.Y] - c.V[o.X]
+			c.V[15] = flag
 		},
 	},
 	{
 		Name: "[8XYE] Vx <<= 1",
-		Is:   func(o *Opcode) bool { return o.F == 8 && o.N == 0xE },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 8 && o.N == 0xE },
+		Execute: func(c *CPU, o Opcode) {
 			VX := c.V[o.X]
 
 			if c.Config.InstructionAssignBeforeShift {
@@ -178,108 +204,114 @@ var Instructions = []Instruction{
 	},
 	{
 		Name: "[9XY0] Skip If Vx != Vy",
-		Is:   func(o *Opcode) bool { return o.F == 9 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 9 },
+		Execute: func(c *CPU, o Opcode) {
 			if c.V[o.X] != c.V[o.Y] {
-				c.PC += 2
+				c.Skip()
 			}
 		},
 	},
 	{
 		Name: "[ANNN] Set Index",
-		Is:   func(o *Opcode) bool { return o.F == 0xA },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 0xA },
+		Execute: func(c *CPU, o Opcode) {
 			c.I = o.NNN
 		},
 	},
 	{
 		Name: "[BNNN] Jump With Offset",
-		Is:   func(o *Opcode) bool { return o.F == 0xB },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 0xB },
+		Execute: func(c *CPU, o Opcode) {
 			if c.Config.InstructionUseVxForOffset {
-				c.PC = uint16(c.V[0]) + o.NNN
-			} else {
 				c.PC = uint16(c.V[o.X]) + o.NNN
+			} else {
+				c.PC = uint16(c.V[0]) + o.NNN
 			}
 		},
 	},
 	{
-		Name: "[CNNN] Rand",
-		Is:   func(o *Opcode) bool { return o.F == 0xC },
-		Execute: func(c *CPU, o *Opcode) {
-			c.V[o.X] = byte(rand.Intn(256)) & o.NN
+		Name: "[CXNN] Vx = rand & NN",
+		Is:   func(o Opcode) bool { return o.F == 0xC },
+		Execute: func(c *CPU, o Opcode) {
+			c.V[o.X] = c.Rand.Byte() & o.NN
 		},
 	},
 	{
 		Name: "[DXYN] Display",
-		Is:   func(o *Opcode) bool { return o.F == 0xD },
-		Execute: func(c *CPU, o *Opcode) {
-			x := int(c.V[o.X])
-			y := int(c.V[o.Y])
-			n := int(o.N)
-			data := make([]byte, 0, n)
-
-			for i := 0; i < n; i++ {
-				data = append(data, c.Memory.Read(c.I+uint16(i)))
-			}
-
-			unset := c.Display.Write(x, y, data)
-
-			if unset {
-				c.V[15] = 1
-			} else {
-				c.V[15] = 0
-			}
+		Is:   func(o Opcode) bool { return o.F == 0xD },
+		Execute: func(c *CPU, o Opcode) {
+			drawSprite(c, o, int(o.N), 8)
 		},
 	},
 	{
 		Name: "[EX9E] Skip If Key Pressed",
-		Is:   func(o *Opcode) bool { return o.F == 0xE && o.NN == 0x9E },
-		Execute: func(c *CPU, o *Opcode) {
-			// TODO: key
+		Is:   func(o Opcode) bool { return o.F == 0xE && o.NN == 0x9E },
+		Execute: func(c *CPU, o Opcode) {
+			if c.Keypad.IsPressed(c.V[o.X] & 0x0F) {
+				c.Skip()
+			}
 		},
 	},
 	{
 		Name: "[EXA1] Skip If Key Not Pressed",
-		Is:   func(o *Opcode) bool { return o.F == 0xE && o.NN == 0xA1 },
-		Execute: func(c *CPU, o *Opcode) {
-			// TODO: key
+		Is:   func(o Opcode) bool { return o.F == 0xE && o.NN == 0xA1 },
+		Execute: func(c *CPU, o Opcode) {
+			if !c.Keypad.IsPressed(c.V[o.X] & 0x0F) {
+				c.Skip()
+			}
 		},
 	},
 	{
 		Name: "[FX07] Vx = DelayTimer",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x07 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x07 },
+		Execute: func(c *CPU, o Opcode) {
 			c.V[o.X] = byte(c.DelayTimer.GetValue())
 		},
 	},
 	{
 		Name: "[FX0A] Get Key",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x0A },
-		Execute: func(c *CPU, o *Opcode) {
-			// TODO: key
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x0A },
+		Execute: func(c *CPU, o Opcode) {
+			// Like the COSMAC VIP, block until a key has been pressed and then released.
+			if !c.WaitingForKey {
+				c.WaitingForKey = true
+				c.Keypad.ClearReleased()
+			}
+
+			key, ok := c.Keypad.TakeReleased()
+			if !ok {
+				c.PC -= 2
+				return
+			}
+
+			c.WaitingForKey = false
+			c.V[o.X] = key
 		},
 	},
 	{
 		Name: "[FX15] DelayTimer = Vx",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x15 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x15 },
+		Execute: func(c *CPU, o Opcode) {
 			c.DelayTimer.SetValue(int(c.V[o.X]))
 		},
 	},
 	{
 		Name: "[FX18] SoundTimer = Vx",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x18 },
-		Execute: func(c *CPU, o *Opcode) {
-			// TODO: sound
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x18 },
+		Execute: func(c *CPU, o Opcode) {
+			c.SoundTimer.SetValue(int(c.V[o.X]))
 		},
 	},
 	{
 		Name: "[FX1E] Add To Index",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x1E },
-		Execute: func(c *CPU, o *Opcode) {
-			if c.Config.InstructionOverflowAddIndex && int(c.I)+int(c.V[o.X]) > 0x0FFF {
-				c.V[15] = 1
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x1E },
+		Execute: func(c *CPU, o Opcode) {
+			if c.Config.InstructionOverflowAddIndex {
+				if int(c.I)+int(c.V[o.X]) > 0x0FFF {
+					c.V[15] = 1
+				} else {
+					c.V[15] = 0
+				}
 			}
 
 			c.I += uint16(c.V[o.X])
@@ -287,15 +319,19 @@ var Instructions = []Instruction{
 	},
 	{
 		Name: "[FX29] Set Index To Font Character",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x29 },
-		Execute: func(c *CPU, o *Opcode) {
-			// TODO: font
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x29 },
+		Execute: func(c *CPU, o Opcode) {
+			c.I = c.FontAddress + uint16(c.V[o.X]&0x0F)*FontCharacterSize
 		},
 	},
 	{
 		Name: "[FX33] Binary-coded Decimal Conversion",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x33 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x33 },
+		Execute: func(c *CPU, o Opcode) {
+			if !c.checkWrite(c.I, 3) {
+				return
+			}
+
 			VX := c.V[o.X]
 
 			c.Memory.Write(c.I, []byte{
@@ -307,8 +343,12 @@ var Instructions = []Instruction{
 	},
 	{
 		Name: "[FX55] Store",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x55 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x55 },
+		Execute: func(c *CPU, o Opcode) {
+			if !c.checkWrite(c.I, int(o.X)+1) {
+				return
+			}
+
 			if c.Config.InstructionModifyIndexOnStoreAndLoad {
 				for i := 0; i <= int(o.X); i++ {
 					c.Memory.Write(c.I, []byte{c.V[i]})
@@ -323,8 +363,8 @@ var Instructions = []Instruction{
 	},
 	{
 		Name: "[FX65] Load",
-		Is:   func(o *Opcode) bool { return o.F == 0xF && o.NN == 0x65 },
-		Execute: func(c *CPU, o *Opcode) {
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x65 },
+		Execute: func(c *CPU, o Opcode) {
 			if c.Config.InstructionModifyIndexOnStoreAndLoad {
 				for i := 0; i <= int(o.X); i++ {
 					c.V[i] = c.Memory.Read(c.I)
@@ -338,3 +378,219 @@ var Instructions = []Instruction{
 		},
 	},
 }
+
+// SCHIPInstructions contains the SUPER-CHIP 1.1 instructions followed by the CHIP-8 instructions.
+var SCHIPInstructions = append([]Instruction{
+	{
+		Name: "[00CN] Scroll Down N",
+		Is:   func(o Opcode) bool { return o.F == 0 && o.X == 0 && o.Y == 0xC },
+		Execute: func(c *CPU, o Opcode) {
+			c.Display.ScrollDown(int(o.N))
+		},
+	},
+	{
+		Name: "[00FB] Scroll Right",
+		Is:   func(o Opcode) bool { return o.Raw == 0x00FB },
+		Execute: func(c *CPU, o Opcode) {
+			c.Display.ScrollRight(4)
+		},
+	},
+	{
+		Name: "[00FC] Scroll Left",
+		Is:   func(o Opcode) bool { return o.Raw == 0x00FC },
+		Execute: func(c *CPU, o Opcode) {
+			c.Display.ScrollLeft(4)
+		},
+	},
+	{
+		Name: "[00FD] Exit",
+		Is:   func(o Opcode) bool { return o.Raw == 0x00FD },
+		Execute: func(c *CPU, o Opcode) {
+			c.Exited = true
+		},
+	},
+	{
+		Name: "[00FE] Low Resolution",
+		Is:   func(o Opcode) bool { return o.Raw == 0x00FE },
+		Execute: func(c *CPU, o Opcode) {
+			c.Display.SetHighRes(false)
+		},
+	},
+	{
+		Name: "[00FF] High Resolution",
+		Is:   func(o Opcode) bool { return o.Raw == 0x00FF },
+		Execute: func(c *CPU, o Opcode) {
+			c.Display.SetHighRes(true)
+		},
+	},
+	{
+		Name: "[DXY0] Display 16x16",
+		Is:   func(o Opcode) bool { return o.F == 0xD && o.N == 0 },
+		Execute: func(c *CPU, o Opcode) {
+			drawSprite(c, o, 16, 16)
+		},
+	},
+	{
+		Name: "[FX30] Set Index To Large Font Character",
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x30 },
+		Execute: func(c *CPU, o Opcode) {
+			// The large font only has the digits 0-9, on XO-CHIP too, so other values
+			// wrap around rather than pointing past the font.
+			c.I = c.BigFontAddress + uint16(c.V[o.X]%10)*BigFontCharacterSize
+		},
+	},
+	{
+		Name: "[FX75] Store Flags",
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x75 },
+		Execute: func(c *CPU, o Opcode) {
+			for i := 0; i <= int(min(o.X, 7)); i++ {
+				c.RPL[i] = c.V[i]
+			}
+		},
+	},
+	{
+		Name: "[FX85] Load Flags",
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x85 },
+		Execute: func(c *CPU, o Opcode) {
+			for i := 0; i <= int(min(o.X, 7)); i++ {
+				c.V[i] = c.RPL[i]
+			}
+		},
+	},
+}, Instructions...)
+
+// XOCHIPInstructions contains the XO-CHIP instructions followed by the SUPER-CHIP instructions.
+var XOCHIPInstructions = append([]Instruction{
+	{
+		Name: "[00DN] Scroll Up N",
+		Is:   func(o Opcode) bool { return o.F == 0 && o.X == 0 && o.Y == 0xD },
+		Execute: func(c *CPU, o Opcode) {
+			c.Display.ScrollUp(int(o.N))
+		},
+	},
+	{
+		Name: "[5XY2] Save Vx To Vy",
+		Is:   func(o Opcode) bool { return o.F == 5 && o.N == 2 },
+		Execute: func(c *CPU, o Opcode) {
+			if !c.checkWrite(c.I, len(registerRange(o.X, o.Y))) {
+				return
+			}
+
+			for i, r := range registerRange(o.X, o.Y) {
+				c.Memory.Write(c.I+uint16(i), []byte{c.V[r]})
+			}
+		},
+	},
+	{
+		Name: "[5XY3] Load Vx To Vy",
+		Is:   func(o Opcode) bool { return o.F == 5 && o.N == 3 },
+		Execute: func(c *CPU, o Opcode) {
+			for i, r := range registerRange(o.X, o.Y) {
+				c.V[r] = c.Memory.Read(c.I + uint16(i))
+			}
+		},
+	},
+	{
+		Name: "[F000] Long Set Index",
+		Is:   func(o Opcode) bool { return o.Raw == 0xF000 },
+		Execute: func(c *CPU, o Opcode) {
+			c.I = uint16(c.Memory.Peek(c.PC))<<0x08 + uint16(c.Memory.Peek(c.PC+1))
+			c.PC += 2
+		},
+	},
+	{
+		Name: "[FN01] Select Planes",
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x01 },
+		Execute: func(c *CPU, o Opcode) {
+			c.Display.SelectPlanes(o.X)
+		},
+	},
+	{
+		Name: "[F002] Load Audio Pattern",
+		Is:   func(o Opcode) bool { return o.Raw == 0xF002 },
+		Execute: func(c *CPU, o Opcode) {
+			for i := range c.AudioPattern {
+				c.AudioPattern[i] = c.Memory.Read(c.I + uint16(i))
+			}
+
+			c.HasAudioPattern = true
+		},
+	},
+	{
+		Name: "[FX3A] Set Pitch",
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x3A },
+		Execute: func(c *CPU, o Opcode) {
+			c.Pitch = c.V[o.X]
+		},
+	},
+	{
+		Name: "[FX75] Store Flags",
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x75 },
+		Execute: func(c *CPU, o Opcode) {
+			for i := 0; i <= int(o.X); i++ {
+				c.RPL[i] = c.V[i]
+			}
+		},
+	},
+	{
+		Name: "[FX85] Load Flags",
+		Is:   func(o Opcode) bool { return o.F == 0xF && o.NN == 0x85 },
+		Execute: func(c *CPU, o Opcode) {
+			for i := 0; i <= int(o.X); i++ {
+				c.V[i] = c.RPL[i]
+			}
+		},
+	},
+}, SCHIPInstructions...)
+
+// registerRange returns the register indexes from x to y inclusive, descending if x > y.
+func registerRange(x, y byte) []byte {
+	r := []byte{}
+
+	if x <= y {
+		for i := x; i <= y; i++ {
+			r = append(r, i)
+		}
+	} else {
+		for i := int(x); i >= int(y); i-- {
+			r = append(r, byte(i))
+		}
+	}
+
+	return r
+}
+
+// drawSprite draws the sprite at I to (Vx, Vy) and sets VF if any pixels were unset.
+// If the display wait quirk is enabled the instruction repeats until the next display refresh.
+func drawSprite(c *CPU, o Opcode, rows, width int) {
+	if c.Config.InstructionWaitForDisplay {
+		if !c.WaitingForDisplay {
+			c.WaitingForDisplay = true
+			c.DisplayWaitFrame = c.Display.Frame
+		}
+
+		if c.Display.Frame == c.DisplayWaitFrame {
+			c.PC -= 2
+			return
+		}
+
+		c.WaitingForDisplay = false
+	}
+
+	x := int(c.V[o.X])
+	y := int(c.V[o.Y])
+	n := rows * (width / 8) * c.Display.PlaneCount()
+	data := make([]byte, 0, n)
+
+	for i := 0; i < n; i++ {
+		data = append(data, c.Memory.Read(c.I+uint16(i)))
+	}
+
+	unset := c.Display.Write(x, y, data, width, c.Config.InstructionClipSprites)
+
+	if unset {
+		c.V[15] = 1
+	} else {
+		c.V[15] = 0
+	}
+}
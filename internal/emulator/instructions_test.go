@@ -0,0 +1,353 @@
+package emulator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArithmeticFlags(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		result byte
+		flag   byte
+	}{
+		{"8XY4 carry", "v0 := 0xFF v1 := 1 v0 += v1", 0x00, 1},
+		{"8XY4 no carry", "v0 := 0xFE v1 := 1 v0 += v1", 0xFF, 0},
+		{"8XY4 into VF", "vf := 0xFF v1 := 2 vf += v1", 1, 1},
+		{"8XY5 equal", "v0 := 5 v1 := 5 v0 -= v1", 0x00, 1},
+		{"8XY5 borrow", "v0 := 4 v1 := 5 v0 -= v1", 0xFF, 0},
+		{"8XY5 into VF", "vf := 4 v1 := 5 vf -= v1", 0, 0},
+		{"8XY7 equal", "v0 := 5 v1 := 5 v0 =- v1", 0x00, 1},
+		{"8XY7 borrow", "v0 := 5 v1 := 4 v0 =- v1", 0xFF, 0},
+		{"8XY6 into VF", "vf := 0x81 vf >>= vf", 1, 1},
+		{"8XYE", "v0 := 0x81 v0 <<= v0", 0x02, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEmulator(t, CHIP8Config.Clone(), ": main "+c.src+" loop again")
+
+			_, err := e.RunHeadless(HeadlessLimits{Instructions: 8})
+			assert.NoError(t, err)
+
+			// The result is in V0, or in VF when the operation targets VF.
+			if !strings.HasSuffix(c.name, "into VF") {
+				assert.Equal(t, c.result, e.CPU.V[0], "result")
+			}
+			assert.Equal(t, c.flag, e.CPU.V[15], "flag")
+		})
+	}
+}
+
+func TestSCHIPFlagsClampToV7(t *testing.T) {
+	e := newTestEmulator(t, SCHIPConfig.Clone(), `: main
+  v0 := 1 v1 := 2 v2 := 3 v3 := 4 v4 := 5 v5 := 6 v6 := 7 v7 := 8 v8 := 9
+  0xF8 0x75
+  loop again`)
+
+	_, err := e.RunHeadless(HeadlessLimits{Instructions: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, e.CPU.RPL[:8])
+}
+
+func TestCHIP8Decodes5XY0Only(t *testing.T) {
+	instructions := InstructionSets[VariantCHIP8]
+
+	assert.NotNil(t, NewOpcode(0x5120).Decode(instructions))
+	assert.Nil(t, NewOpcode(0x5121).Decode(instructions))
+}
+
+func TestAddIndexOverflowFlag(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		flag byte
+	}{
+		{"overflow", "vf := 0 v0 := 2 i := 0xFFF i += v0", 1},
+		{"no overflow clears", "vf := 1 v0 := 2 i := 0x300 i += v0", 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := CHIP8Config.Clone()
+			config.CPU.InstructionOverflowAddIndex = true
+
+			e := newTestEmulator(t, config, ": main "+c.src+" loop again")
+			_, err := e.RunHeadless(HeadlessLimits{Instructions: 5})
+			assert.NoError(t, err)
+			assert.Equal(t, c.flag, e.CPU.V[15])
+		})
+	}
+}
+
+// runSource runs the source, which must end in a loop which jumps to itself, for the
+// number of instructions on a copy of the profile.
+func runSource(t *testing.T, profile, src string, instructions uint64) *Emulator {
+	t.Helper()
+
+	config, err := GetProfile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := newTestEmulator(t, config, src)
+	if _, err := e.RunHeadless(HeadlessLimits{Instructions: instructions}); err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestXOCHIPLongIndex(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		i    uint16
+	}{
+		{"load", "i := long 0x1234", 0x1234},
+		{"skipped", "v0 := 1 if v0 != 1 then i := long 0x1234", 0},
+		{"not skipped", "v0 := 2 if v0 != 1 then i := long 0x1234", 0x1234},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := runSource(t, "xo-chip", ": main "+c.src+" v1 := 5 loop again", 10)
+
+			assert.Equal(t, c.i, e.CPU.I)
+			assert.Equal(t, byte(5), e.CPU.V[1], "the skip lands on the instruction after F000 NNNN")
+		})
+	}
+}
+
+func TestXOCHIPSaveLoadRange(t *testing.T) {
+	e := runSource(t, "xo-chip", `: main
+  v1 := 1 v2 := 2 v3 := 3
+  i := 0x300 save v1 - v3
+  i := 0x310 save v3 - v1
+  i := 0x300 load v4 - v6
+  i := 0x310 load v9 - v7
+  loop again`, 20)
+
+	assert.Equal(t, []byte{1, 2, 3}, []byte{e.Memory.Peek(0x300), e.Memory.Peek(0x301), e.Memory.Peek(0x302)})
+	assert.Equal(t, []byte{3, 2, 1}, []byte{e.Memory.Peek(0x310), e.Memory.Peek(0x311), e.Memory.Peek(0x312)})
+	assert.Equal(t, []byte{1, 2, 3}, e.CPU.V[4:7])
+	assert.Equal(t, []byte{1, 2, 3}, e.CPU.V[7:10], "v9 - v7 loads in descending order")
+	assert.Equal(t, uint16(0x310), e.CPU.I, "I is not changed")
+}
+
+func TestXOCHIPPlanes(t *testing.T) {
+	e := runSource(t, "xo-chip", `: main
+  v0 := 0
+  i := dots
+  plane 3
+  sprite v0 v0 1
+  plane 1
+  clear
+  plane 2
+  sprite v0 v0 1
+  loop again
+: dots 0x80 0x40`, 20)
+
+	// Both planes drew a pixel, the first plane was cleared and the second plane's
+	// first byte was drawn again alone.
+	assert.Equal(t, byte(2), e.Display.Buffer[0])
+	assert.Equal(t, byte(2), e.Display.Buffer[1])
+	assert.Equal(t, byte(0), e.CPU.V[15])
+	assert.Equal(t, byte(2), e.Display.Plane)
+}
+
+func TestXOCHIPAudio(t *testing.T) {
+	e := runSource(t, "xo-chip", `: main
+  i := pattern
+  audio
+  v0 := 100
+  pitch := v0
+  loop again
+: pattern
+  0x00 0x11 0x22 0x33 0x44 0x55 0x66 0x77 0x88 0x99 0xAA 0xBB 0xCC 0xDD 0xEE 0xFF`, 10)
+
+	assert.True(t, e.CPU.HasAudioPattern)
+	assert.Equal(t, [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}, e.CPU.AudioPattern)
+	assert.Equal(t, byte(100), e.CPU.Pitch)
+}
+
+func TestXOCHIPTopOfMemory(t *testing.T) {
+	e := runSource(t, "xo-chip", `: main
+  i := long 0xFFFF
+  v0 := 0xAB
+  save v0
+  i := long 0xFFFF
+  load v1 - v1
+  loop again`, 10)
+
+	assert.Equal(t, byte(0xAB), e.Memory.Peek(0xFFFF))
+	assert.Equal(t, byte(0xAB), e.CPU.V[1])
+	assert.Equal(t, uint16(0xFFFF), e.CPU.I)
+}
+
+func TestSkipIfKey(t *testing.T) {
+	// V1 is set while key 5 is pressed, through EXA1, and V2 while it is not, through EX9E.
+	const src = `: main
+  v0 := 5
+  if v0 key then v1 := 1
+  if v0 -key then v2 := 1
+  loop again`
+
+	cases := []struct {
+		name   string
+		key    byte
+		v1, v2 byte
+	}{
+		{"pressed", 0x5, 1, 0},
+		{"other key", 0x6, 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEmulator(t, CHIP8Config.Clone(), src)
+			e.Keypad.Press(c.key)
+
+			_, err := e.RunHeadless(HeadlessLimits{Instructions: 10})
+			assert.NoError(t, err)
+			assert.Equal(t, c.v1, e.CPU.V[1])
+			assert.Equal(t, c.v2, e.CPU.V[2])
+		})
+	}
+}
+
+func TestGetKeyWaitsForRelease(t *testing.T) {
+	e := newTestEmulator(t, CHIP8Config.Clone(), `: main
+  v0 := key
+  v1 := 1
+  loop again`)
+
+	run := func() {
+		_, err := e.RunHeadless(HeadlessLimits{Instructions: e.CPU.Cycles + 20})
+		assert.NoError(t, err)
+	}
+
+	// A key released before FX0A runs is not taken.
+	e.Keypad.Press(0x3)
+	e.Keypad.Release(0x3)
+	run()
+	assert.True(t, e.CPU.WaitingForKey)
+
+	e.Keypad.Press(0x7)
+	run()
+	assert.True(t, e.CPU.WaitingForKey, "still waiting while the key is held")
+	assert.Equal(t, byte(0), e.CPU.V[1])
+	assert.Equal(t, uint16(0x200), e.CPU.PC)
+
+	e.Keypad.Release(0x7)
+	run()
+	assert.False(t, e.CPU.WaitingForKey)
+	assert.Equal(t, byte(0x7), e.CPU.V[0])
+	assert.Equal(t, byte(1), e.CPU.V[1])
+}
+
+// setPixels returns the x, y coordinates of the set pixels, row by row.
+func setPixels(d *Display) [][2]int {
+	pixels := [][2]int{}
+	for i, p := range d.Buffer {
+		if p != 0 {
+			pixels = append(pixels, [2]int{i % d.Width, i / d.Width})
+		}
+	}
+
+	return pixels
+}
+
+func TestSCHIPScroll(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		pixels [][2]int
+	}{
+		{"down lores", "lores sprite v0 v1 1 scroll-down 3", [][2]int{{8, 3}}},
+		{"right lores", "lores sprite v0 v1 1 scroll-right", [][2]int{{12, 0}}},
+		{"left lores", "lores sprite v0 v1 1 scroll-left", [][2]int{{4, 0}}},
+		{"down hires", "hires sprite v0 v1 1 scroll-down 3", [][2]int{{8, 3}}},
+		{"right hires", "hires sprite v0 v1 1 scroll-right", [][2]int{{12, 0}}},
+		{"left hires", "hires sprite v0 v1 1 scroll-left scroll-left scroll-left", [][2]int{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := runSource(t, "schip-modern", ": main v0 := 8 i := dot "+c.src+" loop again : dot 0x80", 20)
+
+			assert.Equal(t, c.pixels, setPixels(e.Display))
+		})
+	}
+}
+
+func TestSCHIPResolution(t *testing.T) {
+	e := runSource(t, "schip-modern", ": main hires v0 := 127 v1 := 63 i := dot sprite v0 v1 1 loop again : dot 0x80", 10)
+	assert.True(t, e.Display.HighRes)
+	assert.Equal(t, 128, e.Display.Width)
+	assert.Equal(t, 64, e.Display.Height)
+	assert.Equal(t, [][2]int{{127, 63}}, setPixels(e.Display))
+
+	e = runSource(t, "schip-modern", ": main hires i := dot sprite v0 v0 1 lores loop again : dot 0x80", 10)
+	assert.False(t, e.Display.HighRes)
+	assert.Equal(t, 64, e.Display.Width)
+	assert.Equal(t, 32, e.Display.Height)
+	assert.Empty(t, setPixels(e.Display), "switching mode clears the display")
+}
+
+func TestSCHIPDisplay16x16(t *testing.T) {
+	// Each row sets the two pixels at each edge of the 16 pixel wide sprite.
+	e := runSource(t, "schip-modern", `: main
+  hires
+  v0 := 1
+  v1 := 2
+  i := big
+  sprite v0 v1 0
+  loop again
+: big
+  0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03
+  0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03 0xC0 0x03`, 10)
+
+	want := [][2]int{}
+	for y := 2; y < 18; y++ {
+		want = append(want, [2]int{1, y}, [2]int{2, y}, [2]int{15, y}, [2]int{16, y})
+	}
+
+	assert.Equal(t, want, setPixels(e.Display))
+	assert.Equal(t, byte(0), e.CPU.V[15])
+}
+
+func TestSCHIPLargeFont(t *testing.T) {
+	cases := []struct {
+		digit byte
+		i     uint16
+	}{
+		{0, 0xA0},
+		{7, 0xA0 + 7*BigFontCharacterSize},
+		{9, 0xA0 + 9*BigFontCharacterSize},
+		{12, 0xA0 + 2*BigFontCharacterSize},
+	}
+
+	for _, c := range cases {
+		e := runSource(t, "schip-modern", fmt.Sprintf(": main v0 := %d i := bighex v0 loop again", c.digit), 5)
+		assert.Equal(t, c.i, e.CPU.I, "digit %d", c.digit)
+	}
+}
+
+func TestFontCharacter(t *testing.T) {
+	for name := range Profiles {
+		t.Run(name, func(t *testing.T) {
+			for digit := range 16 {
+				e := runSource(t, name, fmt.Sprintf(": main v0 := %d i := hex v0 loop again", digit), 5)
+
+				glyph := e.Config.Memory.FontAddress + uint16(digit*FontCharacterSize)
+				assert.Equal(t, glyph, e.CPU.I, "digit %X", digit)
+				assert.Equal(t, DefaultFont[digit*FontCharacterSize:(digit+1)*FontCharacterSize],
+					e.Memory.Data[glyph:glyph+FontCharacterSize], "digit %X", digit)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package emulator
+
+// KeyCount is the number of keys on the hexadecimal keypad.
+const KeyCount = 16
+
+// Keypad represents the 16 key hexadecimal keypad.
+type Keypad struct {
+	// Keys are the current key states, true if pressed.
+	Keys [KeyCount]bool
+	// Released is the last key released since the last call to ClearReleased, -1 if none.
+	Released int
+}
+
+// NewKeypad returns a new Keypad.
+func NewKeypad() *Keypad {
+	return &Keypad{
+		Keys:     [KeyCount]bool{},
+		Released: -1,
+	}
+}
+
+// Press sets the key as pressed.
+func (k *Keypad) Press(key byte) {
+	if int(key) >= KeyCount {
+		return
+	}
+
+	k.Keys[key] = true
+}
+
+// Release sets the key as released.
+func (k *Keypad) Release(key byte) {
+	if int(key) >= KeyCount {
+		return
+	}
+
+	if k.Keys[key] {
+		k.Released = int(key)
+	}
+
+	k.Keys[key] = false
+}
+
+// SetState sets the state of every key at once, tracking any releases.
+func (k *Keypad) SetState(keys [KeyCount]bool) {
+	for i, pressed := range keys {
+		if pressed {
+			k.Press(byte(i))
+		} else {
+			k.Release(byte(i))
+		}
+	}
+}
+
+// IsPressed returns true if the key is pressed.
+func (k *Keypad) IsPressed(key byte) bool {
+	if int(key) >= KeyCount {
+		return false
+	}
+
+	return k.Keys[key]
+}
+
+// ClearReleased forgets the last released key.
+func (k *Keypad) ClearReleased() {
+	k.Released = -1
+}
+
+// TakeReleased returns the last released key and clears it.
+func (k *Keypad) TakeReleased() (byte, bool) {
+	if k.Released < 0 {
+		return 0, false
+	}
+
+	key := byte(k.Released)
+	k.Released = -1
+
+	return key, true
+}
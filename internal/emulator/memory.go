@@ -3,13 +3,17 @@ package emulator
 // Memory represents a contiguous memory structure.
 type Memory struct {
 	// Size is the size of the memory in bytes.
-	Size uint16
+	Size int
 	// Data is the raw byte data.
 	Data []byte
+	// OnRead if set is called for every Read.
+	OnRead func(addr uint16)
+	// OnWrite if set is called for every byte written by Write.
+	OnWrite func(addr uint16, val byte)
 }
 
 // NewMemory returns a new Memory.
-func NewMemory(size uint16) *Memory {
+func NewMemory(size int) *Memory {
 	m := &Memory{
 		Size: size,
 		Data: make([]byte, size),
@@ -21,18 +25,31 @@ func NewMemory(size uint16) *Memory {
 // Write bytes starting at a specific address.
 func (m *Memory) Write(addr uint16, val []byte) {
 	for i, v := range val {
-		loc := addr + uint16(i)
+		loc := int(addr) + i
 		if loc >= m.Size {
 			return
 		}
 
 		m.Data[loc] = v
+
+		if m.OnWrite != nil {
+			m.OnWrite(uint16(loc), v)
+		}
 	}
 }
 
 // Read bytes at a specific address.
 func (m *Memory) Read(addr uint16) byte {
-	if addr >= m.Size {
+	if m.OnRead != nil {
+		m.OnRead(addr)
+	}
+
+	return m.Peek(addr)
+}
+
+// Peek reads the byte at a specific address without calling OnRead.
+func (m *Memory) Peek(addr uint16) byte {
+	if int(addr) >= m.Size {
 		return 0
 	}
 
@@ -0,0 +1,378 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MovieHeader is the first line of a movie file.
+const MovieHeader = "chippy-movie 1"
+
+var (
+	// ErrMovieInvalid is returned when the data is not a movie or is corrupt.
+	ErrMovieInvalid = errors.New("invalid movie")
+	// ErrMovieROMMismatch is returned when the movie was recorded with a different ROM.
+	ErrMovieROMMismatch = errors.New("movie is for a different ROM")
+	// ErrMovieDesync is returned when playback no longer matches the recording.
+	ErrMovieDesync = errors.New("movie desync")
+	// ErrMovieActive is returned for actions which would break the recording or playback.
+	ErrMovieActive = errors.New("not available while a movie is active")
+)
+
+// MovieInput is the state of the keypad from a frame onward, with bit n set if key n is pressed.
+type MovieInput struct {
+	Frame int
+	Keys  uint16
+}
+
+// Movie is a recording of the keypad input of a run, along with everything needed to
+// play it back: the ROM hash, config, RNG seed and an optional save state to start from.
+//
+// The file is line based text:
+//
+//	chippy-movie 1
+//	rom <sha1 hex>
+//	seed <seed>
+//	config <json>
+//	state <base64 save state>
+//	input <frame> <keys hex>
+//	check <frame> <crc32 hex>
+//	end <frame>
+//
+// The state line is only present for movies which do not start from power-on.
+type Movie struct {
+	ROMHash string
+	Seed    int64
+	Config  *Config
+	State   []byte
+	Inputs  []MovieInput
+	// Checksums are the CRC-32 (IEEE) of the display buffer at the start of a frame.
+	Checksums map[int]uint32
+	// End is the frame at which the recording stopped.
+	End int
+}
+
+// ReadMovie reads a movie.
+func ReadMovie(r io.Reader) (*Movie, error) {
+	m := &Movie{Checksums: map[int]uint32{}}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != MovieHeader {
+		return nil, ErrMovieInvalid
+	}
+
+	line := 1
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		name, value, _ := strings.Cut(text, " ")
+		if err := m.parseLine(name, value); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrMovieInvalid, line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if m.ROMHash == "" || m.Config == nil {
+		return nil, fmt.Errorf("%w: missing rom or config", ErrMovieInvalid)
+	}
+
+	return m, nil
+}
+
+func (m *Movie) parseLine(name, value string) error {
+	var err error
+
+	switch name {
+	case "rom":
+		m.ROMHash = strings.ToLower(value)
+	case "seed":
+		m.Seed, err = strconv.ParseInt(value, 10, 64)
+	case "config":
+		m.Config = CHIP8Config.Clone()
+		err = json.Unmarshal([]byte(value), m.Config)
+	case "state":
+		m.State, err = base64.StdEncoding.DecodeString(value)
+	case "end":
+		m.End, err = strconv.Atoi(value)
+	case "input", "check":
+		var frame int
+		var data uint64
+		frame, data, err = parseFrameValue(value)
+		if err != nil {
+			break
+		}
+
+		if name == "input" {
+			if data > 0xFFFF {
+				return fmt.Errorf("keys %x out of range", data)
+			}
+			m.Inputs = append(m.Inputs, MovieInput{Frame: frame, Keys: uint16(data)})
+		} else {
+			m.Checksums[frame] = uint32(data)
+		}
+	default:
+		return fmt.Errorf("unknown field %q", name)
+	}
+
+	return err
+}
+
+// parseFrameValue parses a decimal frame number followed by a hex value.
+func parseFrameValue(value string) (int, uint64, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("expected a frame and a value")
+	}
+
+	frame, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	data, err := strconv.ParseUint(fields[1], 16, 32)
+
+	return frame, data, err
+}
+
+// Write writes the movie.
+func (m *Movie) Write(w io.Writer) error {
+	config, err := json.Marshal(m.Config)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, MovieHeader)
+	fmt.Fprintf(bw, "rom %s\n", m.ROMHash)
+	fmt.Fprintf(bw, "seed %d\n", m.Seed)
+	fmt.Fprintf(bw, "config %s\n", config)
+	if m.State != nil {
+		fmt.Fprintf(bw, "state %s\n", base64.StdEncoding.EncodeToString(m.State))
+	}
+
+	// Inputs and checksums are interleaved by frame so the file reads in order.
+	frames := make([]int, 0, len(m.Checksums))
+	for frame := range m.Checksums {
+		frames = append(frames, frame)
+	}
+	sort.Ints(frames)
+
+	i := 0
+	for _, frame := range frames {
+		for ; i < len(m.Inputs) && m.Inputs[i].Frame <= frame; i++ {
+			fmt.Fprintf(bw, "input %d %04x\n", m.Inputs[i].Frame, m.Inputs[i].Keys)
+		}
+		fmt.Fprintf(bw, "check %d %08x\n", frame, m.Checksums[frame])
+	}
+	for ; i < len(m.Inputs); i++ {
+		fmt.Fprintf(bw, "input %d %04x\n", m.Inputs[i].Frame, m.Inputs[i].Keys)
+	}
+	fmt.Fprintf(bw, "end %d\n", m.End)
+
+	return bw.Flush()
+}
+
+// LoadMovieFile reads the movie from the file.
+func LoadMovieFile(file string) (*Movie, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := ReadMovie(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return m, nil
+}
+
+// SaveFile writes the movie to the file.
+func (m *Movie) SaveFile(file string) error {
+	buf := &bytes.Buffer{}
+	if err := m.Write(buf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0o644)
+}
+
+// MovieSession records or plays back a movie on an emulator. The keypad only changes at the
+// start of a frame while a movie is active, so the state seen by EX9E, EXA1 and FX0A is
+// fully described by the frame numbered inputs.
+type MovieSession struct {
+	Movie *Movie
+	// Recording is true when recording, false when playing back.
+	Recording bool
+	// Live is the keypad the window writes to, copied to the emulator keypad each frame
+	// while recording and ignored during playback.
+	Live *Keypad
+	// ChecksumInterval is the number of frames between the recorded display checksums,
+	// 0 for none.
+	ChecksumInterval int
+	// Err is set once playback has desynced.
+	Err  error
+	next int
+	last uint16
+}
+
+// RecordMovie starts recording a movie of the keypad input. If fromState is true the current
+// state is embedded so playback starts from it, otherwise playback starts from power-on.
+// A seed is picked if the config does not set one, so that it can be recorded.
+func (e *Emulator) RecordMovie(fromState bool, checksumInterval int) error {
+	if e.Config.CPU.Seed == nil {
+		seed := time.Now().UnixNano()
+		e.Config.CPU.Seed = &seed
+		e.CPU.Rand.Seed(seed)
+	}
+
+	m := &Movie{
+		ROMHash:   HashROM(e.Program),
+		Seed:      *e.Config.CPU.Seed,
+		Config:    e.Config.Clone(),
+		Inputs:    []MovieInput{},
+		Checksums: map[int]uint32{},
+	}
+
+	if fromState {
+		buf := &bytes.Buffer{}
+		if err := e.SaveState(buf); err != nil {
+			return err
+		}
+		m.State = buf.Bytes()
+	}
+
+	s := &MovieSession{
+		Movie:            m,
+		Recording:        true,
+		Live:             NewKeypad(),
+		ChecksumInterval: checksumInterval,
+		last:             keysMask(e.Keypad.Keys),
+	}
+	s.Live.Keys = e.Keypad.Keys
+	e.Clock = NewPacedClock(e.CPU.InstructionTimer.UpdateDelta)
+
+	if s.last != 0 {
+		m.Inputs = append(m.Inputs, MovieInput{Frame: e.Display.Frame, Keys: s.last})
+	}
+
+	e.Movie = s
+
+	return nil
+}
+
+// PlayMovie starts playing back the movie. The emulator should have been created with the
+// movie config and the same ROM.
+func (e *Emulator) PlayMovie(m *Movie) error {
+	if hash := HashROM(e.Program); hash != m.ROMHash {
+		return fmt.Errorf("%w: movie %s, loaded %s", ErrMovieROMMismatch, m.ROMHash, hash)
+	}
+
+	seed := m.Seed
+	e.Config.CPU.Seed = &seed
+	e.CPU.Rand.Seed(seed)
+
+	if m.State != nil {
+		if err := e.LoadState(bytes.NewReader(m.State)); err != nil {
+			return err
+		}
+	}
+
+	e.Movie = &MovieSession{
+		Movie:     m,
+		Recording: false,
+		Live:      NewKeypad(),
+		last:      keysMask(e.Keypad.Keys),
+	}
+	e.Clock = NewPacedClock(e.CPU.InstructionTimer.UpdateDelta)
+
+	return nil
+}
+
+// Frame updates the session at the start of a frame, recording or applying the keypad
+// state and checking the display checksum.
+func (s *MovieSession) Frame(e *Emulator) {
+	frame := e.Display.Frame
+	m := s.Movie
+
+	if s.Recording {
+		if keys := keysMask(s.Live.Keys); keys != s.last {
+			m.Inputs = append(m.Inputs, MovieInput{Frame: frame, Keys: keys})
+			s.last = keys
+		}
+		e.Keypad.SetState(s.Live.Keys)
+
+		if s.ChecksumInterval > 0 && frame%s.ChecksumInterval == 0 {
+			m.Checksums[frame] = crc32.ChecksumIEEE(e.Display.Buffer)
+		}
+
+		return
+	}
+
+	for ; s.next < len(m.Inputs) && m.Inputs[s.next].Frame <= frame; s.next++ {
+		s.last = m.Inputs[s.next].Keys
+	}
+	e.Keypad.SetState(maskKeys(s.last))
+
+	if want, ok := m.Checksums[frame]; ok && s.Err == nil {
+		if got := crc32.ChecksumIEEE(e.Display.Buffer); got != want {
+			s.Err = fmt.Errorf("%w at frame %d: display checksum %08x, recorded %08x", ErrMovieDesync, frame, got, want)
+		}
+	}
+}
+
+// Done returns true once playback has reached the end of the movie.
+func (s *MovieSession) Done(frame int) bool {
+	return !s.Recording && frame >= s.Movie.End
+}
+
+// Stop ends a recording at the current frame.
+func (s *MovieSession) Stop(e *Emulator) {
+	if s.Recording {
+		s.Movie.End = e.Display.Frame
+	}
+}
+
+// keysMask returns the key states as a bitmask with bit n set if key n is pressed.
+func keysMask(keys [KeyCount]bool) uint16 {
+	mask := uint16(0)
+	for i, pressed := range keys {
+		if pressed {
+			mask |= 1 << i
+		}
+	}
+
+	return mask
+}
+
+// maskKeys returns the key states of a bitmask from keysMask.
+func maskKeys(mask uint16) [KeyCount]bool {
+	keys := [KeyCount]bool{}
+	for i := range keys {
+		keys[i] = mask&(1<<i) != 0
+	}
+
+	return keys
+}
@@ -0,0 +1,171 @@
+package emulator
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keySource draws the digit of each key pressed, moving along after each one.
+const keySource = `
+: main
+  loop
+    v0 := key
+    i := hex v0
+    sprite v1 v2 5
+    v1 += 5
+  again`
+
+// moviePresses are the keys held down over ranges of frames while recording.
+var moviePresses = []struct {
+	key        byte
+	start, end int
+}{
+	{0x5, 10, 14},
+	{0xA, 30, 33},
+	{0x1, 45, 50},
+}
+
+// recordMovie records a run of the key source with the presses, one frame at a time.
+func recordMovie(t *testing.T) (*Movie, *Emulator) {
+	t.Helper()
+
+	seed := int64(3)
+	config := CHIP8Config.Clone()
+	config.CPU.Seed = &seed
+
+	e := newTestEmulator(t, config, keySource)
+	if err := e.RecordMovie(false, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	for frame := range 60 {
+		for _, p := range moviePresses {
+			if frame >= p.start && frame < p.end {
+				e.Movie.Live.Press(p.key)
+			} else {
+				e.Movie.Live.Release(p.key)
+			}
+		}
+
+		if _, err := e.RunHeadless(HeadlessLimits{Frames: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return e.Movie.Movie, e
+}
+
+// playMovie returns an emulator playing back the movie.
+func playMovie(t *testing.T, m *Movie) *Emulator {
+	t.Helper()
+
+	e := newTestEmulator(t, m.Config.Clone(), keySource)
+	if err := e.PlayMovie(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestMovieRoundTrip(t *testing.T) {
+	m, recorded := recordMovie(t)
+	assert.Len(t, m.Inputs, 2*len(moviePresses))
+	assert.Equal(t, 60, m.End)
+	assert.NotEmpty(t, m.Checksums)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, m.Write(buf))
+
+	read, err := ReadMovie(bytes.NewReader(buf.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, m.ROMHash, read.ROMHash)
+	assert.Equal(t, m.Seed, read.Seed)
+	assert.Equal(t, m.Inputs, read.Inputs)
+	assert.Equal(t, m.Checksums, read.Checksums)
+	assert.Equal(t, m.End, read.End)
+
+	e := playMovie(t, read)
+	reason, err := e.RunHeadless(HeadlessLimits{})
+	assert.NoError(t, err)
+	assert.Equal(t, StopMovie, reason)
+	assert.Contains(t, recorded.Display.Buffer, byte(1), "keys were drawn")
+	assert.Equal(t, recorded.Display.Buffer, e.Display.Buffer)
+	assert.Equal(t, recorded.CPU.V, e.CPU.V)
+}
+
+func TestMovieDesync(t *testing.T) {
+	m, _ := recordMovie(t)
+
+	e := playMovie(t, m)
+	_, err := e.RunHeadless(HeadlessLimits{Frames: 15})
+	assert.NoError(t, err)
+
+	// The bottom right pixel is never drawn, so the next checksum no longer matches.
+	e.Display.Buffer[len(e.Display.Buffer)-1] ^= 1
+
+	_, err = e.RunHeadless(HeadlessLimits{})
+	assert.True(t, errors.Is(err, ErrMovieDesync), "got %v", err)
+	assert.Contains(t, err.Error(), "at frame 20:")
+	assert.Equal(t, 20, e.Display.Frame)
+}
+
+func TestMovieROMMismatch(t *testing.T) {
+	m, _ := recordMovie(t)
+
+	e := newTestEmulator(t, m.Config.Clone(), keySource+" clear")
+	assert.True(t, errors.Is(e.PlayMovie(m), ErrMovieROMMismatch))
+}
+
+func TestReadMovieInvalid(t *testing.T) {
+	for _, data := range []string{"", "not a movie\n", "chippy-movie 1\nseed 1\n", "chippy-movie 1\nbogus 1\n"} {
+		_, err := ReadMovie(bytes.NewReader([]byte(data)))
+		assert.True(t, errors.Is(err, ErrMovieInvalid), "%q: got %v", data, err)
+	}
+}
+
+func TestMoviePauseRejected(t *testing.T) {
+	seed := int64(3)
+	config := CHIP8Config.Clone()
+	config.CPU.Seed = &seed
+
+	e := newTestEmulator(t, config, keySource)
+	recorder := &FrameRecorder{}
+	e.Display.Renderer = recorder
+	if !assert.NoError(t, e.RecordMovie(false, 10)) {
+		return
+	}
+
+	// Advance as Start does, trying to pause and resume part way through.
+	for e.Display.Frame < 60 {
+		switch e.Display.Frame {
+		case 10:
+			e.Movie.Live.Press(0x5)
+		case 14:
+			e.Movie.Live.Release(0x5)
+		case 20, 30:
+			e.HandleAction(ActionPause)
+			assert.False(t, e.Paused)
+		}
+
+		if !assert.NoError(t, e.Advance(e.CPU.InstructionTimer.UpdateDelta)) {
+			return
+		}
+	}
+
+	assert.Equal(t, ErrMovieActive.Error(), recorder.status)
+
+	m := e.Movie.Movie
+	e.Movie.Stop(e)
+
+	played := playMovie(t, m)
+	reason, err := played.RunHeadless(HeadlessLimits{})
+	assert.NoError(t, err)
+	assert.Equal(t, StopMovie, reason)
+	assert.Equal(t, e.Display.Buffer, played.Display.Buffer)
+}
@@ -1,5 +1,7 @@
 package emulator
 
+import "sync"
+
 // Opcode is the parsed raw opcode.
 type Opcode struct {
 	// Raw is the raw opcode.
@@ -19,8 +21,8 @@ type Opcode struct {
 }
 
 // NewOpcode returns a new Opcode.
-func NewOpcode(raw uint16) *Opcode {
-	return &Opcode{
+func NewOpcode(raw uint16) Opcode {
+	return Opcode{
 		Raw: raw,
 		F:   byte((raw & 0xF000) >> 0x0C),
 		X:   byte((raw & 0x0F00) >> 0x08),
@@ -31,13 +33,47 @@ func NewOpcode(raw uint16) *Opcode {
 	}
 }
 
-// Decode returns the instruction for the opcode.
-func (o *Opcode) Decode() *Instruction {
-	for _, instr := range Instructions {
-		if instr.Is(o) {
-			return &instr
+// Decode returns the instruction for the opcode from the instruction set.
+func (o Opcode) Decode(instructions []Instruction) *Instruction {
+	for i := range instructions {
+		if instructions[i].Is(o) {
+			return &instructions[i]
 		}
 	}
 
 	return nil
 }
+
+// DispatchTable maps every raw opcode to its instruction, nil if there is none.
+type DispatchTable [0x10000]*Instruction
+
+// NewDispatchTable returns the dispatch table for the instruction set, decoding each
+// opcode once so that no Is functions need to be called while running.
+func NewDispatchTable(instructions []Instruction) *DispatchTable {
+	t := &DispatchTable{}
+	for raw := range t {
+		t[raw] = NewOpcode(uint16(raw)).Decode(instructions)
+	}
+
+	return t
+}
+
+var (
+	dispatchTablesMu sync.Mutex
+	dispatchTables   = map[Variant]*DispatchTable{}
+)
+
+// DispatchTableFor returns the dispatch table of the variant's instruction set, which is
+// built on first use and shared from then on.
+func DispatchTableFor(variant Variant) *DispatchTable {
+	dispatchTablesMu.Lock()
+	defer dispatchTablesMu.Unlock()
+
+	t, ok := dispatchTables[variant]
+	if !ok {
+		t = NewDispatchTable(InstructionSets[variant])
+		dispatchTables[variant] = t
+	}
+
+	return t
+}
@@ -0,0 +1,64 @@
+package emulator
+
+import "testing"
+
+func TestDispatchTableMatchesDecode(t *testing.T) {
+	for variant, instructions := range InstructionSets {
+		table := DispatchTableFor(variant)
+
+		for raw := range 0x10000 {
+			want := NewOpcode(uint16(raw)).Decode(instructions)
+			if table[raw] != want {
+				t.Fatalf("%s: %04X dispatches to %v, decodes to %v", variant, raw, table[raw], want)
+			}
+		}
+	}
+}
+
+// benchmarkOpcodes is a spread of opcodes, with the common ones from late in the
+// instruction set where the linear scan is slowest.
+var benchmarkOpcodes = []uint16{0x00E0, 0x1234, 0x6A05, 0x7A01, 0x8AB4, 0xA300, 0xD125, 0xF065, 0xF129, 0xF233}
+
+func BenchmarkDecodeLinear(b *testing.B) {
+	instructions := InstructionSets[VariantXOCHIP]
+
+	for i := 0; b.Loop(); i++ {
+		NewOpcode(benchmarkOpcodes[i%len(benchmarkOpcodes)]).Decode(instructions)
+	}
+}
+
+func BenchmarkDecodeDispatch(b *testing.B) {
+	table := DispatchTableFor(VariantXOCHIP)
+
+	for i := 0; b.Loop(); i++ {
+		_ = table[benchmarkOpcodes[i%len(benchmarkOpcodes)]]
+	}
+}
+
+// BenchmarkStep runs a loop of arithmetic, memory and draw instructions, one Step per iteration.
+func BenchmarkStep(b *testing.B) {
+	src := `
+: main
+  i := digit
+  loop
+    v0 += 1
+    v1 := v0
+    v1 <<= v1
+    v2 := random 0xFF
+    if v2 == 3 then v3 += 2
+    load v3
+    sprite v0 v1 5
+  again
+: digit 0xF0 0x90 0xF0 0x90 0xF0`
+
+	config := SCHIPModernConfig.Clone()
+	seed := int64(1)
+	config.CPU.Seed = &seed
+
+	e := newTestEmulator(b, config, src)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		e.CPU.Step()
+	}
+}
@@ -0,0 +1,130 @@
+package emulator
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PaletteSize is the number of display colours, one for each combination of the two
+// XO-CHIP bitplanes: unset, plane 1, plane 2 and both planes.
+const PaletteSize = 4
+
+// DefaultTheme is the theme used when none is configured.
+const DefaultTheme = "classic"
+
+// Themes are the named colour themes, in palette order.
+var Themes = map[string][PaletteSize]string{
+	"classic": {"#000000", "#ffffff", "#aaaaaa", "#555555"},
+	"amber":   {"#1a0f00", "#ffb000", "#b37b00", "#664600"},
+	"green":   {"#001a00", "#33ff33", "#1fa31f", "#0f520f"},
+	"lcd":     {"#9bbc0f", "#0f380f", "#306230", "#8bac0f"},
+	"octo":    {"#996600", "#ffcc00", "#ff6600", "#662200"},
+}
+
+// ThemeNames returns the names of the themes in order.
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseColor parses a "#rrggbb" hex colour, the "#" being optional.
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q, expected #rrggbb", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q, expected #rrggbb", s)
+	}
+
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}, nil
+}
+
+// ParsePalette returns the display colours, indexed by the bitplanes set in a pixel. They
+// are the theme colours, replaced in order by the custom colours and then by the
+// 256-colour foreground and background indexes if they are set.
+func (c *DisplayConfig) ParsePalette() (color.Palette, error) {
+	name := c.Theme
+	if name == "" {
+		name = DefaultTheme
+	}
+
+	theme, ok := Themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(ThemeNames(), ", "))
+	}
+
+	if len(c.Colors) > PaletteSize {
+		return nil, fmt.Errorf("%d colours given, at most %d are used", len(c.Colors), PaletteSize)
+	}
+
+	hex := theme
+	copy(hex[:], c.Colors)
+
+	palette := make(color.Palette, PaletteSize)
+	for i, s := range hex {
+		rgba, err := ParseColor(s)
+		if err != nil {
+			return nil, err
+		}
+		palette[i] = rgba
+	}
+
+	if c.Background >= 0 {
+		palette[0] = TermColor(c.Background)
+	}
+	if c.Foreground >= 0 {
+		palette[1] = TermColor(c.Foreground)
+	}
+
+	return palette, nil
+}
+
+// Palette returns the display colours from ParsePalette, or the default theme if the
+// config is invalid. New reports an invalid config.
+func (c *DisplayConfig) Palette() color.Palette {
+	palette, err := c.ParsePalette()
+	if err != nil {
+		return (&DisplayConfig{Foreground: -1, Background: -1}).Palette()
+	}
+
+	return palette
+}
+
+// NearestTermColor returns the index of the closest colour in the xterm 256-colour palette,
+// from the colour cube and grey ramp whose colours do not change with the terminal theme.
+func NearestTermColor(c color.Color) int {
+	r, g, b, _ := c.RGBA()
+	best, bestDist := 16, -1
+
+	for i := 16; i < 256; i++ {
+		t := TermColor(i)
+		dr := int(r>>8) - int(t.R)
+		dg := int(g>>8) - int(t.G)
+		db := int(b>>8) - int(t.B)
+
+		if dist := dr*dr + dg*dg + db*db; bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+
+	return best
+}
+
+// supportsTrueColor returns true if the terminal advertises 24-bit colour in COLORTERM.
+func supportsTrueColor() bool {
+	v := strings.ToLower(os.Getenv("COLORTERM"))
+
+	return v == "truecolor" || v == "24bit"
+}
@@ -0,0 +1,80 @@
+package emulator
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePalette(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DisplayConfig
+		want   color.Palette
+		err    string
+	}{
+		{
+			name:   "default",
+			config: DisplayConfig{Foreground: -1, Background: -1},
+			want:   color.Palette{color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}, color.RGBA{170, 170, 170, 255}, color.RGBA{85, 85, 85, 255}},
+		},
+		{
+			name:   "custom colours",
+			config: DisplayConfig{Theme: "octo", Colors: []string{"102030", "#A0B0C0"}, Foreground: -1, Background: -1},
+			want:   color.Palette{color.RGBA{0x10, 0x20, 0x30, 255}, color.RGBA{0xA0, 0xB0, 0xC0, 255}, color.RGBA{0xFF, 0x66, 0x00, 255}, color.RGBA{0x66, 0x22, 0x00, 255}},
+		},
+		{
+			name:   "256-colour indexes",
+			config: DisplayConfig{Theme: "amber", Foreground: 196, Background: 17},
+			want:   color.Palette{color.RGBA{0, 0, 95, 255}, color.RGBA{255, 0, 0, 255}, color.RGBA{0xB3, 0x7B, 0x00, 255}, color.RGBA{0x66, 0x46, 0x00, 255}},
+		},
+		{
+			name:   "unknown theme",
+			config: DisplayConfig{Theme: "sepia", Foreground: -1, Background: -1},
+			err:    `unknown theme "sepia"`,
+		},
+		{
+			name:   "invalid colour",
+			config: DisplayConfig{Colors: []string{"#12345"}, Foreground: -1, Background: -1},
+			err:    `invalid colour "#12345"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.ParsePalette()
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNearestTermColor(t *testing.T) {
+	assert.Equal(t, 231, NearestTermColor(color.RGBA{255, 255, 255, 255}))
+	assert.Equal(t, 16, NearestTermColor(color.RGBA{0, 0, 0, 255}))
+	assert.Equal(t, 214, NearestTermColor(color.RGBA{0xFF, 0xB0, 0x00, 255}))
+	assert.Equal(t, 244, NearestTermColor(color.RGBA{0x80, 0x80, 0x80, 255}))
+}
+
+func TestWindowColors(t *testing.T) {
+	config := XOCHIPConfig.Clone()
+	config.Display.Theme = "octo"
+
+	config.Display.Color = ColorTrue
+	w := NewWindow(config.Input, config.Display)
+	assert.Equal(t, "\033[38;2;255;204;0m", w.fg[1])
+	assert.Equal(t, "\033[48;2;153;102;0m", w.bg[0])
+	// Only cells with other colours than unset and plane 1 need escape codes.
+	assert.Equal(t, "▀", w.halfBlock(1, 0))
+	assert.Equal(t, w.fg[2]+w.bg[3]+"▀"+w.fg[1]+w.bg[0], w.halfBlock(2, 3))
+
+	config.Display.Color = Color256
+	w = NewWindow(config.Input, config.Display)
+	assert.Equal(t, "\033[38;5;220m", w.fg[1])
+}
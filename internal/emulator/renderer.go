@@ -0,0 +1,105 @@
+package emulator
+
+// Renderer draws the display to a frontend.
+type Renderer interface {
+	// Render draws the buffer of width by height pixels, each holding a bitmask of the
+	// bitplanes it is set in.
+	Render(buffer []byte, width, height int)
+	// SetStatus sets a message to show alongside the display.
+	SetStatus(status string)
+}
+
+// InputSource feeds the keypad and hotkey actions from a frontend into the emulator.
+type InputSource interface {
+	// Update applies the input received since the last update to the keypad.
+	Update(delta int64, keypad *Keypad)
+	// TakeActions returns the pending hotkey actions and clears them.
+	TakeActions() []string
+	// ShouldExit returns true if the user has asked to exit.
+	ShouldExit() bool
+}
+
+// Frontend is implemented by renderers and input sources which acquire resources for the
+// duration of Emulator.Start.
+type Frontend interface {
+	Init() error
+	Destroy()
+}
+
+// NullRenderer is a Renderer which draws nothing.
+type NullRenderer struct{}
+
+// Render does nothing.
+func (NullRenderer) Render(buffer []byte, width, height int) {}
+
+// SetStatus does nothing.
+func (NullRenderer) SetStatus(status string) {}
+
+// NullInput is an InputSource with no input which never asks to exit.
+type NullInput struct{}
+
+// Update does nothing.
+func (NullInput) Update(delta int64, keypad *Keypad) {}
+
+// TakeActions returns no actions.
+func (NullInput) TakeActions() []string {
+	return nil
+}
+
+// ShouldExit returns false.
+func (NullInput) ShouldExit() bool {
+	return false
+}
+
+// RecordedFrame is a frame kept by a FrameRecorder.
+type RecordedFrame struct {
+	Width  int
+	Height int
+	Buffer []byte
+	Status string
+}
+
+// FrameRecorder is a Renderer which keeps a copy of every frame in memory, for tests.
+type FrameRecorder struct {
+	// Frames are the rendered frames, oldest first.
+	Frames []RecordedFrame
+	// Limit is the maximum number of frames kept, dropping the oldest, 0 for no limit.
+	Limit  int
+	status string
+}
+
+// NewFrameRecorder returns a new FrameRecorder which keeps up to limit frames, 0 for no limit.
+func NewFrameRecorder(limit int) *FrameRecorder {
+	return &FrameRecorder{
+		Frames: []RecordedFrame{},
+		Limit:  limit,
+	}
+}
+
+// Render keeps a copy of the buffer.
+func (r *FrameRecorder) Render(buffer []byte, width, height int) {
+	r.Frames = append(r.Frames, RecordedFrame{
+		Width:  width,
+		Height: height,
+		Buffer: append([]byte{}, buffer...),
+		Status: r.status,
+	})
+
+	if r.Limit > 0 && len(r.Frames) > r.Limit {
+		r.Frames = r.Frames[len(r.Frames)-r.Limit:]
+	}
+}
+
+// SetStatus sets the status kept with the following frames.
+func (r *FrameRecorder) SetStatus(status string) {
+	r.status = status
+}
+
+// Last returns the most recent frame, or nil if nothing has been rendered.
+func (r *FrameRecorder) Last() *RecordedFrame {
+	if len(r.Frames) == 0 {
+		return nil
+	}
+
+	return &r.Frames[len(r.Frames)-1]
+}
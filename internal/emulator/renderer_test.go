@@ -0,0 +1,52 @@
+package emulator
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartWithFrameRecorder(t *testing.T) {
+	src := `
+: main
+  v0 := 0xA
+  i := hex v0
+  sprite v0 v0 5
+  v1 := 3
+  delay := v1
+  loop
+    v1 := delay
+    if v1 != 0 then
+  again
+  exit`
+
+	recorder := NewFrameRecorder(0)
+	e := newTestEmulator(t, SCHIPModernConfig.Clone(), src, WithRenderer(recorder))
+	e.Clock = NewVirtualClock(e.CPU.InstructionTimer.UpdateDelta)
+
+	assert.NoError(t, e.Start())
+	assert.True(t, e.CPU.Exited)
+
+	last := recorder.Last()
+	if assert.NotNil(t, last) {
+		assert.Equal(t, 64, last.Width)
+		assert.Equal(t, 32, last.Height)
+		// The top row of the A sprite is 0xF0, drawn at (10, 10).
+		assert.Equal(t, []byte{1, 1, 1, 1, 0}, last.Buffer[10*64+10:10*64+15])
+	}
+}
+
+func TestNewFrontend(t *testing.T) {
+	e := newTestEmulator(t, CHIP8Config.Clone(), ": main loop again")
+	assert.Equal(t, NullRenderer{}, e.Display.Renderer)
+	assert.Equal(t, NullInput{}, e.Input)
+	assert.Equal(t, &NullSink{}, e.Audio)
+	assert.Empty(t, e.frontends(), "nothing to initialise")
+
+	recorder := NewFrameRecorder(0)
+	sink := NewBellSink(io.Discard)
+	e = newTestEmulator(t, CHIP8Config.Clone(), ": main loop again", WithRenderer(recorder), WithInput(NullInput{}), WithAudio(sink))
+	assert.Same(t, recorder, e.Display.Renderer)
+	assert.Same(t, sink, e.Audio)
+}
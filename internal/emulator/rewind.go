@@ -0,0 +1,232 @@
+package emulator
+
+// memoryRun is a run of bytes at an offset in memory.
+type memoryRun struct {
+	Offset int
+	Data   []byte
+}
+
+// frameState is the machine state of a single frame apart from memory.
+type frameState struct {
+	PC                uint16
+	I                 uint16
+	V                 [16]uint8
+	RPL               [16]uint8
+	Stack             []uint16
+	TimerValues       [3]int
+	TimerDeltas       [3]int64
+	WaitingForKey     bool
+	WaitingForDisplay bool
+	DisplayWaitFrame  int
+	Exited            bool
+	AudioPattern      [16]byte
+	HasAudioPattern   bool
+	Pitch             byte
+	RandState         uint64
+	Width             int
+	Height            int
+	HighRes           bool
+	Plane             byte
+	Frame             int
+	Buffer            []byte
+}
+
+// rewindEntry is a snapshot in the rewind buffer.
+type rewindEntry struct {
+	State *frameState
+	// Undo are the runs which turn the memory of the next snapshot back into the memory
+	// of this one, nil for the newest snapshot.
+	Undo []memoryRun
+	// Size is the approximate size of the entry in bytes.
+	Size int
+}
+
+// Rewind is a ring buffer of per-frame snapshots for stepping backward in time.
+// Only the newest snapshot's memory is kept in full, each older snapshot stores the
+// changed runs needed to step back to it from the one after.
+type Rewind struct {
+	// Budget is the maximum size of the snapshots in bytes.
+	Budget int
+	// Used is the current size of the snapshots in bytes.
+	Used    int
+	entries []*rewindEntry
+	memory  []byte
+}
+
+// NewRewind returns a new Rewind with the memory budget in bytes.
+func NewRewind(budget int) *Rewind {
+	return &Rewind{
+		Budget:  budget,
+		Used:    0,
+		entries: []*rewindEntry{},
+	}
+}
+
+// Len returns the number of snapshots held.
+func (r *Rewind) Len() int {
+	return len(r.entries)
+}
+
+// Record adds a snapshot of the emulator, dropping the oldest snapshots to stay within the budget.
+func (r *Rewind) Record(e *Emulator) {
+	state := captureFrameState(e)
+	entry := &rewindEntry{
+		State: state,
+		Size:  frameStateSize(state),
+	}
+
+	if len(r.entries) == 0 || len(r.memory) != len(e.Memory.Data) {
+		r.memory = append([]byte{}, e.Memory.Data...)
+		r.entries = r.entries[:0]
+		r.Used = len(r.memory)
+	} else {
+		prev := r.entries[len(r.entries)-1]
+		prev.Undo = diffMemory(r.memory, e.Memory.Data)
+		for _, run := range prev.Undo {
+			copy(r.memory[run.Offset:], e.Memory.Data[run.Offset:run.Offset+len(run.Data)])
+			prev.Size += len(run.Data) + 16
+			r.Used += len(run.Data) + 16
+		}
+	}
+
+	r.entries = append(r.entries, entry)
+	r.Used += entry.Size
+
+	for r.Used > r.Budget && len(r.entries) > 1 {
+		r.Used -= r.entries[0].Size
+		r.entries[0] = nil
+		r.entries = r.entries[1:]
+	}
+}
+
+// StepBack drops the newest snapshot and restores the emulator to the one before it.
+// It returns false if there is nothing to step back to.
+func (r *Rewind) StepBack(e *Emulator) bool {
+	if len(r.entries) < 2 {
+		return false
+	}
+
+	newest := r.entries[len(r.entries)-1]
+	r.entries = r.entries[:len(r.entries)-1]
+	r.Used -= newest.Size
+
+	prev := r.entries[len(r.entries)-1]
+	for _, run := range prev.Undo {
+		copy(r.memory[run.Offset:], run.Data)
+		r.Used -= len(run.Data) + 16
+		prev.Size -= len(run.Data) + 16
+	}
+	prev.Undo = nil
+
+	applyFrameState(e, prev.State)
+	copy(e.Memory.Data, r.memory)
+
+	return true
+}
+
+// Clear drops all of the snapshots.
+func (r *Rewind) Clear() {
+	r.entries = r.entries[:0]
+	r.memory = nil
+	r.Used = 0
+}
+
+// diffMemory returns the runs of old which differ from new. Runs separated by small
+// gaps are merged, as each run has an overhead.
+func diffMemory(old, new []byte) []memoryRun {
+	runs := []memoryRun{}
+
+	for i := 0; i < len(old); i++ {
+		if old[i] == new[i] {
+			continue
+		}
+
+		start := i
+		end := i + 1
+		for j := i + 1; j < len(old) && j < end+8; j++ {
+			if old[j] != new[j] {
+				end = j + 1
+			}
+		}
+
+		runs = append(runs, memoryRun{
+			Offset: start,
+			Data:   append([]byte{}, old[start:end]...),
+		})
+
+		i = end - 1
+	}
+
+	return runs
+}
+
+func captureFrameState(e *Emulator) *frameState {
+	c := e.CPU
+	d := e.Display
+
+	s := &frameState{
+		PC:                c.PC,
+		I:                 c.I,
+		V:                 c.V,
+		RPL:               c.RPL,
+		Stack:             append([]uint16{}, c.Stack.Data[:c.Stack.Count]...),
+		WaitingForKey:     c.WaitingForKey,
+		WaitingForDisplay: c.WaitingForDisplay,
+		DisplayWaitFrame:  c.DisplayWaitFrame,
+		Exited:            c.Exited,
+		AudioPattern:      c.AudioPattern,
+		HasAudioPattern:   c.HasAudioPattern,
+		Pitch:             c.Pitch,
+		RandState:         c.Rand.State,
+		Width:             d.Width,
+		Height:            d.Height,
+		HighRes:           d.HighRes,
+		Plane:             d.Plane,
+		Frame:             d.Frame,
+		Buffer:            append([]byte{}, d.Buffer...),
+	}
+
+	for i, t := range []*Timer{c.DelayTimer, c.SoundTimer, c.InstructionTimer} {
+		s.TimerValues[i] = t.Value
+		s.TimerDeltas[i] = t.Delta
+	}
+
+	return s
+}
+
+func applyFrameState(e *Emulator, s *frameState) {
+	c := e.CPU
+	d := e.Display
+
+	c.PC = s.PC
+	c.I = s.I
+	c.V = s.V
+	c.RPL = s.RPL
+	c.Stack = &Stack{Count: len(s.Stack), Data: append([]uint16{}, s.Stack...)}
+	c.WaitingForKey = s.WaitingForKey
+	c.WaitingForDisplay = s.WaitingForDisplay
+	c.DisplayWaitFrame = s.DisplayWaitFrame
+	c.Exited = s.Exited
+	c.AudioPattern = s.AudioPattern
+	c.HasAudioPattern = s.HasAudioPattern
+	c.Pitch = s.Pitch
+	c.Rand.State = s.RandState
+
+	for i, t := range []*Timer{c.DelayTimer, c.SoundTimer, c.InstructionTimer} {
+		t.Value = s.TimerValues[i]
+		t.Delta = s.TimerDeltas[i]
+	}
+
+	d.Width = s.Width
+	d.Height = s.Height
+	d.HighRes = s.HighRes
+	d.Plane = s.Plane
+	d.Frame = s.Frame
+	d.Buffer = append([]byte{}, s.Buffer...)
+	d.Changed = true
+}
+
+// frameStateSize returns the approximate size of the state in bytes.
+func frameStateSize(s *frameState) int {
+	return 160 + len(s.Stack)*2 + len(s.Buffer)
+}
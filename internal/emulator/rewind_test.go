@@ -0,0 +1,109 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rewindSource writes a counter to memory on each pass of its loop.
+const rewindSource = `
+: main
+  loop
+    i := data
+    v0 += 1
+    save v0
+  again
+: data 0`
+
+// snapshot is the part of the emulator a rewind must restore.
+type snapshot struct {
+	PC     uint16
+	V      [16]byte
+	Memory []byte
+}
+
+func takeSnapshot(e *Emulator) snapshot {
+	return snapshot{e.CPU.PC, e.CPU.V, append([]byte{}, e.Memory.Data...)}
+}
+
+// recordFrames steps a pass of the loop before each of n recorded snapshots.
+func recordFrames(t *testing.T, e *Emulator, r *Rewind, n int) []snapshot {
+	snapshots := []snapshot{}
+
+	for range n {
+		for range 4 {
+			assert.NoError(t, e.CPU.Step())
+		}
+
+		r.Record(e)
+		snapshots = append(snapshots, takeSnapshot(e))
+		assertRewindUsed(t, r)
+	}
+
+	return snapshots
+}
+
+// assertRewindUsed checks Used against the full memory copy plus each entry and its undo runs.
+func assertRewindUsed(t *testing.T, r *Rewind) {
+	t.Helper()
+
+	used := len(r.memory)
+	for _, entry := range r.entries {
+		size := frameStateSize(entry.State)
+		for _, run := range entry.Undo {
+			size += len(run.Data) + 16
+		}
+
+		assert.Equal(t, size, entry.Size, "entry size")
+		used += entry.Size
+	}
+
+	assert.Equal(t, used, r.Used, "used")
+}
+
+func TestRewindStepBack(t *testing.T) {
+	e := newTestEmulator(t, CHIP8Config.Clone(), rewindSource)
+	r := NewRewind(1 << 20)
+
+	snapshots := recordFrames(t, e, r, 5)
+	assert.Equal(t, 5, r.Len())
+
+	for i := len(snapshots) - 2; i >= 0; i-- {
+		assert.True(t, r.StepBack(e))
+		assert.Equal(t, snapshots[i], takeSnapshot(e), "frame %d", i)
+		assertRewindUsed(t, r)
+	}
+
+	assert.False(t, r.StepBack(e), "nothing before the first frame")
+	assert.Equal(t, snapshots[0], takeSnapshot(e))
+
+	// Recording carries on from the restored frame.
+	more := recordFrames(t, e, r, 1)
+	assert.True(t, r.StepBack(e))
+	assert.Equal(t, snapshots[0], takeSnapshot(e))
+	assert.NotEqual(t, more[0].Memory, snapshots[0].Memory)
+}
+
+func TestRewindBudget(t *testing.T) {
+	e := newTestEmulator(t, CHIP8Config.Clone(), rewindSource)
+
+	// Room for the memory and three frames, with a little over for their undo runs.
+	frame := frameStateSize(captureFrameState(e))
+	r := NewRewind(len(e.Memory.Data) + 3*frame + 100)
+
+	snapshots := recordFrames(t, e, r, 10)
+	assert.Equal(t, 3, r.Len())
+	assert.LessOrEqual(t, r.Used, r.Budget)
+
+	assert.True(t, r.StepBack(e))
+	assert.Equal(t, snapshots[8], takeSnapshot(e))
+	assert.True(t, r.StepBack(e))
+	assert.Equal(t, snapshots[7], takeSnapshot(e))
+	assert.False(t, r.StepBack(e), "older frames were dropped")
+	assertRewindUsed(t, r)
+
+	r.Clear()
+	assert.Equal(t, 0, r.Len())
+	assert.Equal(t, 0, r.Used)
+}
@@ -0,0 +1,46 @@
+package emulator
+
+// RNG is a xorshift64* random number generator. Unlike math/rand its state is a
+// single value, so it can be saved and restored along with the rest of the machine.
+type RNG struct {
+	// State is the current state, never zero.
+	State uint64
+}
+
+// NewRNG returns a new RNG seeded with the seed.
+func NewRNG(seed int64) *RNG {
+	r := &RNG{}
+	r.Seed(seed)
+
+	return r
+}
+
+// Seed resets the state from the seed, mixing it so that nearby seeds give unrelated sequences.
+func (r *RNG) Seed(seed int64) {
+	z := uint64(seed) + 0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z ^= z >> 31
+
+	if z == 0 {
+		z = 1
+	}
+
+	r.State = z
+}
+
+// Uint64 returns the next random value.
+func (r *RNG) Uint64() uint64 {
+	x := r.State
+	x ^= x >> 12
+	x ^= x << 25
+	x ^= x >> 27
+	r.State = x
+
+	return x * 0x2545F4914F6CDD1D
+}
+
+// Byte returns the next random byte.
+func (r *RNG) Byte() byte {
+	return byte(r.Uint64() >> 56)
+}
@@ -0,0 +1,142 @@
+package emulator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ROMQuirks are the optional quirk overrides for a ROM, nil values keep the profile default.
+type ROMQuirks struct {
+	Shift         *bool `json:"shift,omitempty"`
+	Jump          *bool `json:"jump,omitempty"`
+	IndexOverflow *bool `json:"indexOverflow,omitempty"`
+	MemoryIndex   *bool `json:"memoryIndex,omitempty"`
+	VFReset       *bool `json:"vfReset,omitempty"`
+	DisplayWait   *bool `json:"displayWait,omitempty"`
+	Clip          *bool `json:"clip,omitempty"`
+}
+
+// ROMEntry is the ROM database entry for a single ROM.
+type ROMEntry struct {
+	// Title is the title of the ROM.
+	Title string `json:"title"`
+	// Platform is the name of the profile the ROM runs on.
+	Platform string `json:"platform"`
+	// TickRate is the number of instructions per frame, 0 to keep the profile default.
+	TickRate int `json:"tickrate,omitempty"`
+	// Quirks are the quirk overrides for the ROM.
+	Quirks ROMQuirks `json:"quirks"`
+}
+
+// ROMDatabase is a database of ROM entries keyed by the lowercase hex SHA-1 of the ROM.
+type ROMDatabase struct {
+	Entries map[string]*ROMEntry
+}
+
+// DefaultROMDatabasePath returns the default location of the ROM database in the user config directory.
+func DefaultROMDatabasePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "chippy", "roms.json")
+}
+
+// LoadROMDatabase loads a JSON ROM database file.
+func LoadROMDatabase(file string) (*ROMDatabase, error) {
+	data, err := LoadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]*ROMEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("rom database %s: %w", file, err)
+	}
+
+	db := &ROMDatabase{
+		Entries: make(map[string]*ROMEntry, len(entries)),
+	}
+
+	for hash, entry := range entries {
+		if entry == nil {
+			return nil, fmt.Errorf("rom database %s: %s has no entry", file, hash)
+		}
+
+		if _, ok := Profiles[entry.Platform]; !ok {
+			return nil, fmt.Errorf("rom database %s: %s has unknown platform %q", file, hash, entry.Platform)
+		}
+
+		// Hashes are matched case-insensitively, as hex is written either way.
+		db.Entries[strings.ToLower(hash)] = entry
+	}
+
+	return db, nil
+}
+
+// HashROM returns the hex SHA-1 of the ROM data.
+func HashROM(program []byte) string {
+	sum := sha1.Sum(program)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup returns the entry for the ROM data.
+func (db *ROMDatabase) Lookup(program []byte) (*ROMEntry, bool) {
+	entry, ok := db.Entries[strings.ToLower(HashROM(program))]
+
+	return entry, ok
+}
+
+// Config returns the config for the entry, based on its platform profile.
+func (e *ROMEntry) Config() (*Config, error) {
+	config, err := GetProfile(e.Platform)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.TickRate > 0 {
+		config.CPU.InstructionTimerFrequency = e.TickRate * config.Display.Frequency
+	}
+
+	overrides := []struct {
+		value *bool
+		quirk *bool
+	}{
+		{e.Quirks.Shift, &config.CPU.InstructionAssignBeforeShift},
+		{e.Quirks.Jump, &config.CPU.InstructionUseVxForOffset},
+		{e.Quirks.IndexOverflow, &config.CPU.InstructionOverflowAddIndex},
+		{e.Quirks.MemoryIndex, &config.CPU.InstructionModifyIndexOnStoreAndLoad},
+		{e.Quirks.VFReset, &config.CPU.InstructionResetFlagOnLogic},
+		{e.Quirks.DisplayWait, &config.CPU.InstructionWaitForDisplay},
+		{e.Quirks.Clip, &config.CPU.InstructionClipSprites},
+	}
+
+	for _, o := range overrides {
+		if o.value != nil {
+			*o.quirk = *o.value
+		}
+	}
+
+	return config, nil
+}
+
+// ConfigFor returns the config for the ROM data from its entry, or the fallback if it has none.
+func (db *ROMDatabase) ConfigFor(program []byte, fallback *Config) (*Config, error) {
+	if db == nil {
+		return fallback, nil
+	}
+
+	entry, ok := db.Lookup(program)
+	if !ok {
+		return fallback, nil
+	}
+
+	return entry.Config()
+}
@@ -0,0 +1,58 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeROMDatabase(t *testing.T, contents string) string {
+	file := filepath.Join(t.TempDir(), "roms.json")
+	assert.NoError(t, os.WriteFile(file, []byte(contents), 0o644))
+
+	return file
+}
+
+func TestLoadROMDatabase(t *testing.T) {
+	program := []byte{0x00, 0xE0, 0x12, 0x00}
+	hash := strings.ToUpper(HashROM(program))
+
+	db, err := LoadROMDatabase(writeROMDatabase(t, `{"`+hash+`": {"title": "Clear", "platform": "schip-modern", "tickrate": 20, "quirks": {"clip": false}}}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	entry, ok := db.Lookup(program)
+	if assert.True(t, ok, "upper case hash is found") {
+		assert.Equal(t, "Clear", entry.Title)
+	}
+
+	config, err := db.ConfigFor(program, CHIP8Config)
+	assert.NoError(t, err)
+	assert.Equal(t, 20*config.Display.Frequency, config.CPU.InstructionTimerFrequency)
+	assert.False(t, config.CPU.InstructionClipSprites)
+}
+
+func TestLoadROMDatabaseErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{"null entry", `{"abcd": null}`, "abcd has no entry"},
+		{"unknown platform", `{"abcd": {"platform": "nope"}}`, `unknown platform "nope"`},
+		{"invalid json", `{`, "unexpected end of JSON input"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := LoadROMDatabase(writeROMDatabase(t, c.contents))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package emulator
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TermColor returns the RGB colour of an index in the xterm 256-colour palette.
+func TermColor(index int) color.RGBA {
+	standard := [16][3]uint8{
+		{0, 0, 0}, {128, 0, 0}, {0, 128, 0}, {128, 128, 0},
+		{0, 0, 128}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192},
+		{128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+		{0, 0, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+	}
+	levels := [6]uint8{0, 95, 135, 175, 215, 255}
+
+	index &= 0xFF
+
+	switch {
+	case index < 16:
+		c := standard[index]
+		return color.RGBA{c[0], c[1], c[2], 0xFF}
+	case index < 232:
+		i := index - 16
+		return color.RGBA{levels[i/36], levels[i/6%6], levels[i%6], 0xFF}
+	default:
+		g := uint8(8 + (index-232)*10)
+		return color.RGBA{g, g, g, 0xFF}
+	}
+}
+
+// Image returns the display buffer as an image in the display palette. The scale is the
+// number of image pixels per low-resolution pixel, so high resolution images are the same size.
+func (d *Display) Image(scale int) *image.Paletted {
+	return NewFrameImage(d.Buffer, d.Width, d.Height, captureScale(scale, d.Config.Width, d.Width), d.Config.Palette())
+}
+
+// WritePNG writes the display buffer as a PNG image at the scale.
+func (d *Display) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, d.Image(scale))
+}
+
+// WriteText writes the display buffer as text, with '#' for set pixels and '.' for unset pixels.
+func (d *Display) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for y := 0; y < d.Height; y++ {
+		for x := 0; x < d.Width; x++ {
+			if d.Buffer[y*d.Width+x] != 0 {
+				bw.WriteByte('#')
+			} else {
+				bw.WriteByte('.')
+			}
+		}
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
+
+// SaveScreenshot writes the display buffer to the file, as a PNG image at the scale if the
+// file has a .png extension and as text otherwise.
+func (d *Display) SaveScreenshot(file string, scale int) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".png") {
+		err = d.WritePNG(f, scale)
+	} else {
+		err = d.WriteText(f)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// captureScale returns the number of image pixels per pixel of a display the width, for a
+// scale given for the low-resolution width.
+func captureScale(scale, lowResWidth, width int) int {
+	return max(scale*lowResWidth/max(width, 1), 1)
+}
@@ -0,0 +1,319 @@
+package emulator
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// StateMagic identifies a save state file.
+var StateMagic = [4]byte{'C', 'H', 'P', 'S'}
+
+// StateVersion is the current version of the save state format.
+const StateVersion = 3
+
+var (
+	// ErrStateInvalid is returned when the data is not a save state or is corrupt.
+	ErrStateInvalid = errors.New("invalid save state")
+	// ErrStateVersion is returned when the save state is from another version of the format.
+	ErrStateVersion = errors.New("unsupported save state version")
+	// ErrStateROMMismatch is returned when the save state was made with a different ROM.
+	ErrStateROMMismatch = errors.New("save state is for a different ROM")
+	// ErrStateVariantMismatch is returned when the save state was made with a different variant.
+	ErrStateVariantMismatch = errors.New("save state is for a different variant")
+)
+
+// ROMHash returns the SHA-1 of the loaded program.
+func (e *Emulator) ROMHash() [sha1.Size]byte {
+	return sha1.Sum(e.Program)
+}
+
+// SaveState writes the full machine state.
+//
+// The format is the magic "CHPS", a uint16 version, the payload and a CRC-32 (IEEE) of
+// everything before it. All values are little-endian. The payload records the ROM hash,
+// variant and config ahead of the CPU, timer, memory, display and keypad state.
+// Only states of the current version can be loaded.
+func (e *Emulator) SaveState(w io.Writer) error {
+	config, err := json.Marshal(e.Config)
+	if err != nil {
+		return err
+	}
+
+	sw := &stateWriter{buf: &bytes.Buffer{}}
+	sw.write(StateMagic)
+	sw.write(uint16(StateVersion))
+
+	sw.write(e.ROMHash())
+	sw.write(uint8(e.Config.Variant))
+	sw.writeBytes(config)
+
+	c := e.CPU
+	sw.write(c.PC)
+	sw.write(c.I)
+	sw.write(c.V)
+	sw.write(c.RPL)
+	sw.write(uint16(c.Stack.Count))
+	sw.write(c.Stack.Data[:c.Stack.Count])
+	for _, t := range []*Timer{c.DelayTimer, c.SoundTimer, c.InstructionTimer} {
+		sw.write(int32(t.Value))
+		sw.write(t.Delta)
+	}
+	sw.write(c.WaitingForKey)
+	sw.write(c.WaitingForDisplay)
+	sw.write(int32(c.DisplayWaitFrame))
+	sw.write(c.Exited)
+	sw.write(c.AudioPattern)
+	sw.write(c.HasAudioPattern)
+	sw.write(c.Pitch)
+	sw.write(c.Rand.State)
+	sw.write(c.Cycles)
+
+	sw.writeBytes(e.Memory.Data)
+
+	d := e.Display
+	sw.write(uint16(d.Width))
+	sw.write(uint16(d.Height))
+	sw.write(d.HighRes)
+	sw.write(d.Plane)
+	sw.write(int32(d.Frame))
+	sw.writeBytes(d.Buffer)
+
+	sw.write(e.Keypad.Keys)
+	sw.write(int8(e.Keypad.Released))
+
+	if sw.err != nil {
+		return sw.err
+	}
+
+	sw.write(crc32.ChecksumIEEE(sw.buf.Bytes()))
+	if sw.err != nil {
+		return sw.err
+	}
+
+	_, err = w.Write(sw.buf.Bytes())
+
+	return err
+}
+
+// LoadState restores the machine state written by SaveState. It fails without changing
+// anything if the state is corrupt, was made with a different ROM or variant, or does not
+// fit the display of the loaded config.
+func (e *Emulator) LoadState(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 10 || !bytes.Equal(data[:4], StateMagic[:]) {
+		return ErrStateInvalid
+	}
+
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrStateInvalid)
+	}
+
+	sr := &stateReader{r: bytes.NewReader(body[4:])}
+
+	var version uint16
+	sr.read(&version)
+	if sr.err == nil && version != StateVersion {
+		return fmt.Errorf("%w: %d", ErrStateVersion, version)
+	}
+
+	var hash [sha1.Size]byte
+	sr.read(&hash)
+	if sr.err == nil && hash != e.ROMHash() {
+		return fmt.Errorf("%w: state %x, loaded %x", ErrStateROMMismatch, hash, e.ROMHash())
+	}
+
+	var variant uint8
+	sr.read(&variant)
+	if sr.err == nil && Variant(variant) != e.Config.Variant {
+		return fmt.Errorf("%w: state %s, loaded %s", ErrStateVariantMismatch, Variant(variant), e.Config.Variant)
+	}
+
+	// The config is informational, the current config is kept.
+	sr.readBytes()
+
+	cpu := *e.CPU
+	var stackCount uint16
+	sr.read(&cpu.PC)
+	sr.read(&cpu.I)
+	sr.read(&cpu.V)
+	sr.read(&cpu.RPL)
+	sr.read(&stackCount)
+	stack := make([]uint16, stackCount)
+	sr.read(stack)
+
+	timers := [3]struct {
+		Value int32
+		Delta int64
+	}{}
+	for i := range timers {
+		sr.read(&timers[i].Value)
+		sr.read(&timers[i].Delta)
+	}
+
+	var displayWaitFrame int32
+	sr.read(&cpu.WaitingForKey)
+	sr.read(&cpu.WaitingForDisplay)
+	sr.read(&displayWaitFrame)
+	sr.read(&cpu.Exited)
+	sr.read(&cpu.AudioPattern)
+	sr.read(&cpu.HasAudioPattern)
+	sr.read(&cpu.Pitch)
+
+	var rngState uint64
+	sr.read(&rngState)
+	sr.read(&cpu.Cycles)
+
+	memory := sr.readBytes()
+
+	var width, height uint16
+	var highRes bool
+	var plane byte
+	var frame int32
+	sr.read(&width)
+	sr.read(&height)
+	sr.read(&highRes)
+	sr.read(&plane)
+	sr.read(&frame)
+	buffer := sr.readBytes()
+
+	var keys [KeyCount]bool
+	var released int8
+	sr.read(&keys)
+	sr.read(&released)
+
+	if sr.err != nil {
+		return fmt.Errorf("%w: %v", ErrStateInvalid, sr.err)
+	}
+
+	if len(memory) != e.Memory.Size {
+		return fmt.Errorf("%w: memory size %d does not match %d", ErrStateInvalid, len(memory), e.Memory.Size)
+	}
+
+	config := e.Config.Display
+	wantWidth, wantHeight := config.Width, config.Height
+	if highRes {
+		wantWidth, wantHeight = config.HighResWidth, config.HighResHeight
+	}
+	if int(width) != wantWidth || int(height) != wantHeight || len(buffer) != wantWidth*wantHeight {
+		return fmt.Errorf("%w: display size %dx%d does not match %dx%d", ErrStateInvalid, width, height, wantWidth, wantHeight)
+	}
+
+	if int(plane) >= 1<<config.Planes {
+		return fmt.Errorf("%w: plane %d is past the %d planes", ErrStateInvalid, plane, config.Planes)
+	}
+
+	if released < -1 || int(released) >= KeyCount {
+		return fmt.Errorf("%w: released key %d", ErrStateInvalid, released)
+	}
+
+	// Everything has been read and checked, so the state can now be applied.
+	cpu.Stack = &Stack{Count: len(stack), Data: stack}
+	cpu.DisplayWaitFrame = int(displayWaitFrame)
+	cpu.Rand = &RNG{State: rngState}
+	for i, t := range []*Timer{e.CPU.DelayTimer, e.CPU.SoundTimer, e.CPU.InstructionTimer} {
+		t.Value = int(timers[i].Value)
+		t.Delta = timers[i].Delta
+	}
+	*e.CPU = cpu
+
+	copy(e.Memory.Data, memory)
+
+	d := e.Display
+	d.Width = int(width)
+	d.Height = int(height)
+	d.HighRes = highRes
+	d.Plane = plane
+	d.Frame = int(frame)
+	d.Buffer = buffer
+	d.Changed = true
+
+	e.Keypad.Keys = keys
+	e.Keypad.Released = int(released)
+
+	return nil
+}
+
+// SaveStateFile writes the state to the file.
+func (e *Emulator) SaveStateFile(file string) error {
+	buf := &bytes.Buffer{}
+	if err := e.SaveState(buf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0o644)
+}
+
+// LoadStateFile restores the state from the file.
+func (e *Emulator) LoadStateFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := e.LoadState(f); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	return nil
+}
+
+// stateWriter writes little-endian values, keeping the first error.
+type stateWriter struct {
+	buf *bytes.Buffer
+	err error
+}
+
+func (w *stateWriter) write(v any) {
+	if w.err == nil {
+		w.err = binary.Write(w.buf, binary.LittleEndian, v)
+	}
+}
+
+// writeBytes writes a uint32 length followed by the bytes.
+func (w *stateWriter) writeBytes(b []byte) {
+	w.write(uint32(len(b)))
+	w.write(b)
+}
+
+// stateReader reads little-endian values, keeping the first error.
+type stateReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *stateReader) read(v any) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, v)
+	}
+}
+
+// readBytes reads a uint32 length followed by the bytes.
+func (r *stateReader) readBytes() []byte {
+	var n uint32
+	r.read(&n)
+
+	if r.err != nil || int64(n) > int64(r.r.Len()) {
+		if r.err == nil {
+			r.err = io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+
+	b := make([]byte, n)
+	r.read(b)
+
+	return b
+}
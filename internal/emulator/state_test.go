@@ -0,0 +1,228 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stateSource draws, calls a subroutine and counts, so every part of the state changes.
+const stateSource = `
+: main
+  i := hex v0
+  sprite v0 v0 5
+  sub
+  loop
+    v2 += 1
+    v3 := random 0xFF
+  again
+: sub
+  v1 := 7
+  delay := v1
+  return`
+
+func saveState(t *testing.T, e *Emulator) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	if err := e.SaveState(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// resign replaces the CRC of the state after it has been edited.
+func resign(data []byte) {
+	body := data[:len(data)-4]
+	binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.ChecksumIEEE(body))
+}
+
+// editedState returns the state saved after the edit is made to a run of the state source.
+func editedState(t *testing.T, edit func(e *Emulator)) []byte {
+	t.Helper()
+
+	e := newTestEmulator(t, CHIP8Config.Clone(), stateSource)
+	if _, err := e.RunHeadless(HeadlessLimits{Instructions: 20}); err != nil {
+		t.Fatal(err)
+	}
+	edit(e)
+
+	return saveState(t, e)
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	e := newTestEmulator(t, CHIP8Config.Clone(), stateSource)
+	_, err := e.RunHeadless(HeadlessLimits{Instructions: 20})
+	assert.NoError(t, err)
+	e.Keypad.Released = 0xB
+
+	data := saveState(t, e)
+
+	cpu := *e.CPU
+	stack := append([]uint16{}, e.CPU.Stack.Data[:e.CPU.Stack.Count]...)
+	rng := *e.CPU.Rand
+	delay := e.CPU.DelayTimer.GetValue()
+	memory := append([]byte{}, e.Memory.Data...)
+	buffer := append([]byte{}, e.Display.Buffer...)
+
+	_, err = e.RunHeadless(HeadlessLimits{Instructions: 50})
+	assert.NoError(t, err)
+	assert.NotEqual(t, cpu.V, e.CPU.V, "state moved on before loading")
+
+	assert.NoError(t, e.LoadState(bytes.NewReader(data)))
+
+	assert.Equal(t, cpu.PC, e.CPU.PC)
+	assert.Equal(t, cpu.I, e.CPU.I)
+	assert.Equal(t, cpu.V, e.CPU.V)
+	assert.Equal(t, cpu.Cycles, e.CPU.Cycles)
+	assert.Equal(t, uint64(20), e.CPU.Cycles)
+	assert.Equal(t, stack, e.CPU.Stack.Data[:e.CPU.Stack.Count])
+	assert.Equal(t, rng, *e.CPU.Rand)
+	assert.Equal(t, delay, e.CPU.DelayTimer.GetValue())
+	assert.Equal(t, memory, e.Memory.Data)
+	assert.Equal(t, buffer, e.Display.Buffer)
+	assert.Equal(t, 0xB, e.Keypad.Released)
+	assert.Equal(t, data, saveState(t, e), "saving again gives the same state")
+}
+
+func TestLoadStateErrors(t *testing.T) {
+	e := newTestEmulator(t, CHIP8Config.Clone(), stateSource)
+	_, err := e.RunHeadless(HeadlessLimits{Instructions: 20})
+	assert.NoError(t, err)
+
+	valid := saveState(t, e)
+
+	cases := []struct {
+		name   string
+		target *Emulator
+		edit   func(data []byte) []byte
+		err    error
+	}{
+		{
+			name: "corrupt",
+			edit: func(data []byte) []byte {
+				data[len(data)/2] ^= 0xFF
+				return data
+			},
+			err: ErrStateInvalid,
+		},
+		{
+			name: "bad magic",
+			edit: func(data []byte) []byte {
+				data[0] = 'X'
+				resign(data)
+				return data
+			},
+			err: ErrStateInvalid,
+		},
+		{
+			name: "truncated",
+			edit: func(data []byte) []byte {
+				data = data[:len(data)/2]
+				resign(data)
+				return data
+			},
+			err: ErrStateInvalid,
+		},
+		{
+			name: "newer version",
+			edit: func(data []byte) []byte {
+				binary.LittleEndian.PutUint16(data[4:], StateVersion+1)
+				resign(data)
+				return data
+			},
+			err: ErrStateVersion,
+		},
+		{
+			name: "older version",
+			edit: func(data []byte) []byte {
+				binary.LittleEndian.PutUint16(data[4:], StateVersion-1)
+				resign(data)
+				return data
+			},
+			err: ErrStateVersion,
+		},
+		{
+			name: "version 0",
+			edit: func(data []byte) []byte {
+				binary.LittleEndian.PutUint16(data[4:], 0)
+				resign(data)
+				return data
+			},
+			err: ErrStateVersion,
+		},
+		{
+			name: "zero width",
+			edit: func([]byte) []byte {
+				return editedState(t, func(e *Emulator) {
+					e.Display.Width = 0
+					e.Display.Buffer = nil
+				})
+			},
+			err: ErrStateInvalid,
+		},
+		{
+			name: "high resolution size in low resolution",
+			edit: func([]byte) []byte {
+				return editedState(t, func(e *Emulator) {
+					e.Display.Width = 128
+					e.Display.Height = 64
+					e.Display.Buffer = make([]byte, 128*64)
+				})
+			},
+			err: ErrStateInvalid,
+		},
+		{
+			name: "plane past the planes",
+			edit: func([]byte) []byte {
+				return editedState(t, func(e *Emulator) {
+					e.Display.Plane = 0x02
+				})
+			},
+			err: ErrStateInvalid,
+		},
+		{
+			name: "released key past the keys",
+			edit: func([]byte) []byte {
+				return editedState(t, func(e *Emulator) {
+					e.Keypad.Released = KeyCount
+				})
+			},
+			err: ErrStateInvalid,
+		},
+		{
+			name:   "different ROM",
+			target: newTestEmulator(t, CHIP8Config.Clone(), stateSource+" 0x00"),
+			err:    ErrStateROMMismatch,
+		},
+		{
+			name:   "different variant",
+			target: newTestEmulator(t, SCHIPConfig.Clone(), stateSource),
+			err:    ErrStateVariantMismatch,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := append([]byte{}, valid...)
+			if c.edit != nil {
+				data = c.edit(data)
+			}
+
+			target := c.target
+			if target == nil {
+				target = newTestEmulator(t, CHIP8Config.Clone(), stateSource)
+			}
+			before := saveState(t, target)
+
+			err := target.LoadState(bytes.NewReader(data))
+			assert.True(t, errors.Is(err, c.err), "got %v", err)
+			assert.Equal(t, before, saveState(t, target), "state is unchanged")
+		})
+	}
+}
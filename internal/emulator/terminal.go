@@ -0,0 +1,301 @@
+package emulator
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// escapeTimeout is how long an incomplete escape sequence waits for the rest of its bytes
+// before it is taken to be a lone ESC.
+const escapeTimeout = 50 * time.Millisecond
+
+// Terminal is an input backend which reads keystrokes from a TTY in raw mode.
+type Terminal struct {
+	// In is the input the keystrokes are read from.
+	In *os.File
+	// Keys receives the decoded key names read from In.
+	Keys chan []string
+	// savedState is the TTY state to restore on Close, empty if it was not changed.
+	savedState string
+	// replies receives the raw input instead of Keys while a query waits for its reply.
+	replies  chan []byte
+	querying atomic.Bool
+	// decoder holds an escape sequence split across reads, pending since the time.
+	mu           sync.Mutex
+	decoder      KeyDecoder
+	pendingSince time.Time
+}
+
+// NewTerminal returns a new Terminal reading from the file.
+func NewTerminal(in *os.File) *Terminal {
+	return &Terminal{
+		In:      in,
+		Keys:    make(chan []string, 64),
+		replies: make(chan []byte, 64),
+	}
+}
+
+// Open puts the TTY into raw mode and starts reading keystrokes.
+func (t *Terminal) Open() error {
+	if err := t.makeRaw(); err != nil {
+		return err
+	}
+
+	go t.read()
+
+	return nil
+}
+
+// Close restores the TTY to the state it was in before Open.
+func (t *Terminal) Close() error {
+	if t.savedState == "" {
+		return nil
+	}
+
+	err := t.restore()
+	t.savedState = ""
+
+	return err
+}
+
+// Poll returns all of the keys read since the last call. An escape sequence which is still
+// incomplete after the escape timeout is returned as a lone ESC.
+func (t *Terminal) Poll() []string {
+	keys := []string{}
+
+	for {
+		select {
+		case k := <-t.Keys:
+			keys = append(keys, k...)
+		default:
+			t.mu.Lock()
+			if t.decoder.Pending() && time.Since(t.pendingSince) >= escapeTimeout {
+				keys = append(keys, t.decoder.Flush()...)
+			}
+			t.mu.Unlock()
+
+			return keys
+		}
+	}
+}
+
+// Query writes the query to out and reads the reply until done returns true for it. It returns
+// false if the reply is not complete before the timeout, as when the terminal does not
+// understand the query. Keys pressed while waiting are read as part of the reply.
+func (t *Terminal) Query(out io.Writer, query string, done func(reply []byte) bool, timeout time.Duration) ([]byte, bool) {
+	t.querying.Store(true)
+	defer t.querying.Store(false)
+
+	if _, err := io.WriteString(out, query); err != nil {
+		return nil, false
+	}
+
+	reply := []byte{}
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case data := <-t.replies:
+			reply = append(reply, data...)
+			if done(reply) {
+				return reply, true
+			}
+		case <-deadline:
+			return reply, false
+		}
+	}
+}
+
+func (t *Terminal) read() {
+	buf := make([]byte, 256)
+
+	for {
+		n, err := t.In.Read(buf)
+		if n > 0 {
+			if t.querying.Load() {
+				t.replies <- bytes.Clone(buf[:n])
+			} else {
+				t.mu.Lock()
+				keys := t.decoder.Decode(buf[:n])
+				if t.decoder.Pending() {
+					t.pendingSince = time.Now()
+				}
+				t.mu.Unlock()
+
+				t.Keys <- keys
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// csiKeys maps the final byte of a CSI or SS3 escape sequence to a key name.
+var csiKeys = map[byte]string{
+	'A': "up",
+	'B': "down",
+	'C': "right",
+	'D': "left",
+	'H': "home",
+	'F': "end",
+	'P': "f1",
+	'Q': "f2",
+	'R': "f3",
+	'S': "f4",
+}
+
+// tildeKeys maps the parameter of a "CSI n ~" escape sequence to a key name.
+var tildeKeys = map[string]string{
+	"2":  "insert",
+	"3":  "delete",
+	"5":  "pageup",
+	"6":  "pagedown",
+	"15": "f5",
+	"17": "f6",
+	"18": "f7",
+	"19": "f8",
+	"20": "f9",
+	"21": "f10",
+	"23": "f11",
+	"24": "f12",
+}
+
+// DecodeKeys decodes raw terminal input, including escape sequences, into key names.
+// Printable characters are returned lowercased, control characters as "ctrl+<letter>" and
+// characters after ESC as "alt+<key>". An incomplete escape sequence at the end is
+// returned as "esc" followed by its keys, use a KeyDecoder to decode input in parts.
+func DecodeKeys(data []byte) []string {
+	d := &KeyDecoder{}
+
+	return append(d.Decode(data), d.Flush()...)
+}
+
+// maxEscapeLength is the longest escape sequence kept waiting for the rest of its bytes.
+const maxEscapeLength = 16
+
+// KeyDecoder decodes terminal input which arrives in parts, keeping an escape sequence
+// split across reads until the rest of it arrives.
+type KeyDecoder struct {
+	pending []byte
+}
+
+// Pending returns true if the end of the input so far is an incomplete escape sequence,
+// which is either completed by the next input or is a lone ESC to be flushed.
+func (d *KeyDecoder) Pending() bool {
+	return len(d.pending) > 0
+}
+
+// Decode returns the keys of the input, keeping an incomplete escape sequence at the end
+// for the next call.
+func (d *KeyDecoder) Decode(input []byte) []string {
+	data := append(d.pending, input...)
+	d.pending = nil
+	keys := []string{}
+
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+
+		if b != 0x1B {
+			if name, ok := byteKey(b); ok {
+				keys = append(keys, name)
+			}
+			continue
+		}
+
+		if i+1 >= len(data) {
+			d.pending = bytes.Clone(data[i:])
+			break
+		}
+
+		next := data[i+1]
+		if next == 0x1B {
+			keys = append(keys, "esc")
+			continue
+		}
+
+		if next != '[' && next != 'O' {
+			if name, ok := byteKey(next); ok {
+				keys = append(keys, "alt+"+name)
+			}
+			i++
+			continue
+		}
+
+		// Collect the parameters up to the final byte of the sequence.
+		j := i + 2
+		for j < len(data) && (data[j] < 0x40 || data[j] > 0x7E) {
+			j++
+		}
+
+		if j >= len(data) {
+			if len(data)-i < maxEscapeLength {
+				d.pending = bytes.Clone(data[i:])
+				break
+			}
+
+			keys = append(keys, "esc")
+			continue
+		}
+
+		params := string(data[i+2 : j])
+		if data[j] == '~' {
+			if name, ok := tildeKeys[params]; ok {
+				keys = append(keys, name)
+			}
+		} else if name, ok := csiKeys[data[j]]; ok {
+			keys = append(keys, name)
+		}
+
+		i = j
+	}
+
+	return keys
+}
+
+// Flush returns the keys of the pending incomplete escape sequence, a lone ESC being "esc".
+func (d *KeyDecoder) Flush() []string {
+	if len(d.pending) == 0 {
+		return []string{}
+	}
+
+	data := d.pending
+	d.pending = nil
+
+	keys := []string{"esc"}
+	for _, b := range data[1:] {
+		if name, ok := byteKey(b); ok {
+			keys = append(keys, name)
+		}
+	}
+
+	return keys
+}
+
+// byteKey returns the name of the key for a byte which is not part of an escape sequence.
+func byteKey(b byte) (string, bool) {
+	switch {
+	case b == '\r' || b == '\n':
+		return "enter", true
+	case b == '\t':
+		return "tab", true
+	case b == ' ':
+		return "space", true
+	case b == 0x7F || b == 0x08:
+		return "backspace", true
+	case b == 0x00:
+		return "", false
+	case b == 0x1B:
+		return "esc", true
+	case b < 0x20:
+		return "ctrl+" + string(rune('a'+b-1)), true
+	}
+
+	return strings.ToLower(string(rune(b))), true
+}
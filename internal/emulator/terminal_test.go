@@ -0,0 +1,45 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"printable", "aB1 ", []string{"a", "b", "1", "space"}},
+		{"control", "\x03\r\x7f", []string{"ctrl+c", "enter", "backspace"}},
+		{"arrows", "\x1b[A\x1bOB", []string{"up", "down"}},
+		{"function keys", "\x1b[15~\x1b[20~", []string{"f5", "f9"}},
+		{"alt", "\x1bx", []string{"alt+x"}},
+		{"double esc", "\x1b\x1b[C", []string{"esc", "right"}},
+		{"lone esc", "\x1b", []string{"esc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DecodeKeys([]byte(tt.data)))
+		})
+	}
+}
+
+func TestKeyDecoderSplitSequence(t *testing.T) {
+	d := &KeyDecoder{}
+
+	assert.Equal(t, []string{"a"}, d.Decode([]byte("a\x1b")))
+	assert.True(t, d.Pending())
+	assert.Equal(t, []string{}, d.Decode([]byte("[1")))
+	assert.True(t, d.Pending())
+	assert.Equal(t, []string{"f5", "q"}, d.Decode([]byte("5~q")))
+	assert.False(t, d.Pending())
+
+	// A lone ESC is only known once nothing follows it.
+	assert.Equal(t, []string{}, d.Decode([]byte("\x1b")))
+	assert.Equal(t, []string{"esc"}, d.Flush())
+	assert.Equal(t, []string{}, d.Flush())
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package emulator
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// makeRaw saves the TTY state and puts it into raw mode with stty.
+func (t *Terminal) makeRaw() error {
+	state, err := t.stty("-g")
+	if err != nil {
+		return err
+	}
+
+	t.savedState = strings.TrimSpace(state)
+
+	_, err = t.stty("raw", "-echo")
+
+	return err
+}
+
+// restore puts the TTY back into the saved state.
+func (t *Terminal) restore() error {
+	_, err := t.stty(t.savedState)
+
+	return err
+}
+
+// Size returns the number of columns and rows of the TTY.
+func (t *Terminal) Size() (int, int, error) {
+	out, err := t.stty("size")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var rows, cols int
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil {
+		return 0, 0, err
+	}
+
+	return cols, rows, nil
+}
+
+func (t *Terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.In
+
+	out, err := cmd.Output()
+
+	return string(out), err
+}
@@ -0,0 +1,94 @@
+package emulator
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Console modes, see https://learn.microsoft.com/en-us/windows/console/setconsolemode.
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+// consoleScreenBufferInfo is the CONSOLE_SCREEN_BUFFER_INFO structure.
+type consoleScreenBufferInfo struct {
+	size              [2]int16
+	cursorPosition    [2]int16
+	attributes        uint16
+	window            [4]int16
+	maximumWindowSize [2]int16
+}
+
+// makeRaw saves the console input mode and switches it to unbuffered virtual terminal
+// input, so keys arrive as the same bytes and escape sequences as on Unix. It also
+// enables escape sequences in the output.
+func (t *Terminal) makeRaw() error {
+	in := syscall.Handle(t.In.Fd())
+
+	var mode uint32
+	if err := syscall.GetConsoleMode(in, &mode); err != nil {
+		return err
+	}
+
+	t.savedState = strconv.FormatUint(uint64(mode), 10)
+
+	raw := mode&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if err := setConsoleMode(in, raw); err != nil {
+		return err
+	}
+
+	out := syscall.Handle(os.Stdout.Fd())
+
+	var outMode uint32
+	if err := syscall.GetConsoleMode(out, &outMode); err != nil {
+		return err
+	}
+
+	return setConsoleMode(out, outMode|enableVirtualTerminalProcessing)
+}
+
+// restore puts the console input back into the saved mode.
+func (t *Terminal) restore() error {
+	mode, err := strconv.ParseUint(t.savedState, 10, 32)
+	if err != nil {
+		return err
+	}
+
+	return setConsoleMode(syscall.Handle(t.In.Fd()), uint32(mode))
+}
+
+// Size returns the number of columns and rows of the console window.
+func (t *Terminal) Size() (int, int, error) {
+	var info consoleScreenBufferInfo
+
+	r, _, err := procGetConsoleScreenBufferInfo.Call(os.Stdout.Fd(), uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0, 0, err
+	}
+
+	cols := int(info.window[2]-info.window[0]) + 1
+	rows := int(info.window[3]-info.window[1]) + 1
+
+	return cols, rows, nil
+}
+
+func setConsoleMode(handle syscall.Handle, mode uint32) error {
+	r, _, err := procSetConsoleMode.Call(uintptr(handle), uintptr(mode))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
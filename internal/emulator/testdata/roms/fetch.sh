@@ -0,0 +1,15 @@
+#!/bin/sh
+# Fetches the Timendus CHIP-8 test suite ROMs used by the conformance tests into this
+# directory. REF is pinned to the release the golden screens were checked against, so
+# that the ROMs cannot change under them; set REF to fetch another tag or commit.
+set -eu
+
+REF="${REF:-v4.1}"
+BASE="https://raw.githubusercontent.com/Timendus/chip8-test-suite/$REF/bin"
+
+cd "$(dirname "$0")"
+
+for rom in 2-ibm-logo.ch8 3-corax+.ch8 4-flags.ch8 5-quirks.ch8 6-keypad.ch8; do
+	curl -fsSL -o "$rom" "$BASE/$(printf '%s' "$rom" | sed 's/+/%2B/g')"
+	echo "fetched $rom"
+done
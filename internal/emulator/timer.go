@@ -12,26 +12,43 @@ type Timer struct {
 	UpdateDelta int64
 	// Frequency is the frequency of the timer in Hz.
 	Frequency int
+	// Countdown if true then the timer stops at zero rather than restarting.
+	Countdown bool
 }
 
-// NewTimer returns a new Timer.
+// NewTimer returns a new Timer which restarts from Frequency once it reaches zero.
 func NewTimer(freq int) *Timer {
 	return &Timer{
 		Value:       0,
 		Delta:       0,
 		UpdateDelta: int64(time.Second / time.Duration(freq)),
 		Frequency:   freq,
+		Countdown:   false,
 	}
 }
 
-// Tick uses the delta from the main clock cycle to update the internal state.
+// NewCountdownTimer returns a new Timer which counts down to zero and stays there.
+func NewCountdownTimer(freq int) *Timer {
+	t := NewTimer(freq)
+	t.Countdown = true
+
+	return t
+}
+
+// Tick uses the delta from the main clock cycle to update the internal state, performing
+// at most one update. The rest of the delta is kept, so when the delta spans several
+// periods the further updates are performed by the next calls, such as Tick(0).
 func (t *Timer) Tick(delta int64) bool {
 	t.Delta += delta
 
 	if t.Delta >= t.UpdateDelta {
-		t.Delta = 0
+		t.Delta -= t.UpdateDelta
 
 		if t.Value == 0 {
+			if t.Countdown {
+				return true
+			}
+
 			t.Value = t.Frequency
 			return true
 		}
@@ -0,0 +1,43 @@
+package emulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimerKeepsRemainder(t *testing.T) {
+	instructions := NewTimer(700)
+	display := NewTimer(60)
+
+	frames := 0
+	for range 7000 {
+		if display.Tick(instructions.UpdateDelta) {
+			frames++
+		}
+	}
+
+	// 7000 instructions at 700Hz are ten seconds, not a frame every 12 instructions.
+	assert.InDelta(t, 600, frames, 1)
+}
+
+func TestCPUTickRunsDueInstructions(t *testing.T) {
+	for _, name := range []string{"vip", "schip-modern", "xo-chip"} {
+		t.Run(name, func(t *testing.T) {
+			config, err := GetProfile(name)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			e := newTestEmulator(t, config, ": main loop again")
+
+			// A second in steps which each span many instruction periods.
+			for range 60 {
+				assert.NoError(t, e.CPU.Tick(int64(time.Second/60)))
+			}
+
+			assert.InEpsilon(t, config.CPU.InstructionTimerFrequency, e.CPU.Cycles, 0.001)
+		})
+	}
+}
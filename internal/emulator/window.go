@@ -2,68 +2,533 @@ package emulator
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
-// TODO: handle input.
+const (
+	// graphicsQueryTimeout is how long to wait for the terminal to answer the graphics query.
+	graphicsQueryTimeout = 500 * time.Millisecond
+	// defaultImageScale is the image pixels per low-resolution pixel when the size of the
+	// terminal in pixels is not known.
+	defaultImageScale = 8
+	// kittyImageID is the id of the Kitty image each frame replaces.
+	kittyImageID = 1
+)
+
+// KeyEvent is a change in the state of a keypad key.
+type KeyEvent struct {
+	// Key is the keypad key (0x0-0xF).
+	Key byte
+	// Pressed is true if the key was pressed, false if released.
+	Pressed bool
+}
 
-// Window represents the window which controls rendering and input.
+// Window is the ANSI terminal frontend, which is both the Renderer and the InputSource.
 type Window struct {
+	Config        *InputConfig
+	DisplayConfig *DisplayConfig
+	Terminal      *Terminal
+	// Events are the pending key events not yet applied to the keypad.
+	Events []KeyEvent
+	// Held is the remaining hold time in nanoseconds for each pressed key.
+	Held map[byte]int64
+	// Actions are the pending hotkey actions not yet taken by the emulator.
+	Actions []string
+	// Status is a message shown below the display.
+	Status string
+	// Exit is true once the user has requested an exit.
+	Exit bool
+	// Cols and Rows are the size of the terminal, 0 if unknown.
+	Cols int
+	Rows int
+	// Output is where the frames are written.
+	Output io.Writer
+	// FullRedraw if true then every frame is drawn in full rather than only the changed cells.
+	FullRedraw bool
+	// Mode is the render mode in use, which falls back to half-block if the terminal does
+	// not answer for the configured graphics protocol.
+	Mode RenderMode
+	// Graphics is what the terminal reported it supports, for the image modes.
+	Graphics GraphicsSupport
+	// TrueColor is true if colours are written as 24-bit rather than 256-colour escape codes.
+	TrueColor bool
+	// fg and bg are the escape codes for the foreground and background palette colours.
+	fg      [PaletteSize]string
+	bg      [PaletteSize]string
+	signals chan os.Signal
+	resize  chan os.Signal
+	// last is the last rendered frame, drawn again when the terminal is resized.
+	last       []byte
+	lastWidth  int
+	lastHeight int
+	// screen is the presented cells, so only changed cells are written. It is nil when
+	// the screen must be drawn in full.
+	screen       []string
+	screenCols   int
+	screenStatus string
 }
 
 // NewWindow returns a new Window.
-func NewWindow() *Window {
-	return &Window{}
+func NewWindow(config *InputConfig, displayConfig *DisplayConfig) *Window {
+	w := &Window{
+		Config:        config,
+		DisplayConfig: displayConfig,
+		Terminal:      NewTerminal(os.Stdin),
+		Events:        []KeyEvent{},
+		Held:          map[byte]int64{},
+		Exit:          false,
+		Output:        os.Stdout,
+		Mode:          displayConfig.Mode,
+		TrueColor:     displayConfig.Color == ColorTrue || (displayConfig.Color == ColorAuto && supportsTrueColor()),
+		signals:       make(chan os.Signal, 1),
+		resize:        make(chan os.Signal, 1),
+	}
+	w.updateColors()
+
+	return w
+}
+
+// PushKeyEvent queues a key event to be applied on the next Update.
+func (w *Window) PushKeyEvent(key byte, pressed bool) {
+	w.Events = append(w.Events, KeyEvent{Key: key, Pressed: pressed})
+}
+
+// Update reads the terminal input and applies the pending key events to the keypad.
+// Keys are released once they have not been seen for the hold timeout.
+func (w *Window) Update(delta int64, keypad *Keypad) {
+	select {
+	case <-w.signals:
+		w.Exit = true
+	case <-w.resize:
+		w.updateSize()
+		w.screen = nil
+		if w.last != nil {
+			w.Render(w.last, w.lastWidth, w.lastHeight)
+		}
+	default:
+	}
+
+	for key, remaining := range w.Held {
+		remaining -= delta
+		if remaining <= 0 {
+			delete(w.Held, key)
+			w.PushKeyEvent(key, false)
+		} else {
+			w.Held[key] = remaining
+		}
+	}
+
+	for _, name := range w.Terminal.Poll() {
+		if name == "ctrl+c" {
+			w.Exit = true
+			continue
+		}
+
+		if action, ok := w.Config.Hotkeys[name]; ok {
+			w.Actions = append(w.Actions, action)
+			continue
+		}
+
+		key, ok := w.Config.Keymap[name]
+		if !ok {
+			continue
+		}
+
+		if _, held := w.Held[key]; !held {
+			w.PushKeyEvent(key, true)
+		}
+
+		w.Held[key] = int64(time.Duration(w.Config.HoldTimeout) * time.Millisecond)
+	}
+
+	for _, ev := range w.Events {
+		if ev.Pressed {
+			keypad.Press(ev.Key)
+		} else {
+			keypad.Release(ev.Key)
+		}
+	}
+
+	w.Events = w.Events[:0]
+}
+
+// TakeActions returns the pending hotkey actions and clears them.
+func (w *Window) TakeActions() []string {
+	actions := w.Actions
+	w.Actions = []string{}
+
+	return actions
 }
 
 // Init the window.
-func (w *Window) Init() {
+func (w *Window) Init() error {
+	if err := w.DisplayConfig.Mode.Validate(); err != nil {
+		return err
+	}
+	w.Mode = w.DisplayConfig.Mode
+
+	if err := w.DisplayConfig.Color.Validate(); err != nil {
+		return err
+	}
+	w.updateColors()
+
+	if err := w.Terminal.Open(); err != nil {
+		return err
+	}
+
+	signal.Notify(w.signals, os.Interrupt, syscall.SIGTERM)
+	notifyResize(w.resize)
+	w.updateSize()
+
+	if w.Mode == RenderKitty || w.Mode == RenderSixel {
+		w.detectGraphics()
+	}
+
 	sb := &strings.Builder{}
 	w.clear(sb)
-	fmt.Print(sb.String())
+	sb.WriteString("\033[?25l")
+	io.WriteString(w.Output, sb.String())
+	w.screen = nil
+
+	return nil
 }
 
 // ShouldExit returns true if the window has requested an exit.
 func (w *Window) ShouldExit() bool {
-	return false
+	return w.Exit
 }
 
-// Destroy frees the acquired resources.
+// Destroy frees the acquired resources and restores the terminal.
 func (w *Window) Destroy() {
+	signal.Stop(w.signals)
+	signal.Stop(w.resize)
+	w.Terminal.Close()
+	io.WriteString(w.Output, "\033[0m\033[?25h\n")
+}
+
+// SetStatus sets the message shown below the display.
+func (w *Window) SetStatus(status string) {
+	w.Status = status
+}
+
+// Render the buffer to the window. Only the cells which changed since the last frame are
+// written, wrapped in a synchronized update if enabled so the terminal shows the frame at once.
+func (w *Window) Render(buffer []byte, width, height int) {
+	w.last = buffer
+	w.lastWidth = width
+	w.lastHeight = height
+
+	if w.Mode == RenderKitty || w.Mode == RenderSixel {
+		w.renderImage(buffer, width, height)
+		return
+	}
+
+	cells, cols := w.cells(buffer, width, height)
+
+	sb := &strings.Builder{}
+	if w.DisplayConfig.SyncOutput {
+		sb.WriteString("\033[?2026h")
+	}
+
+	if w.FullRedraw || w.screen == nil || len(cells) != len(w.screen) || cols != w.screenCols {
+		w.drawFull(sb, cells, cols)
+	} else {
+		w.drawChanged(sb, cells, cols)
+	}
+
+	if w.DisplayConfig.SyncOutput {
+		sb.WriteString("\033[?2026l")
+	}
+
+	w.screen = cells
+	w.screenCols = cols
+	w.screenStatus = w.Status
+
+	io.WriteString(w.Output, sb.String())
 }
 
-// Render the buffer to the window.
-func (w *Window) Render(buffer []byte) {
+// renderImage draws the buffer as an image with the graphics protocol of the mode, and the
+// status on the bottom row.
+func (w *Window) renderImage(buffer []byte, width, height int) {
+	img := NewFrameImage(buffer, width, height, w.imageScale(width, height), w.DisplayConfig.Palette())
+
 	sb := &strings.Builder{}
+	if w.DisplayConfig.SyncOutput {
+		sb.WriteString("\033[?2026h")
+	}
+
+	if w.screen == nil {
+		w.clear(sb)
+	}
+	sb.WriteString("\033[H")
+
+	if w.Mode == RenderKitty {
+		EncodeKitty(sb, img, kittyImageID)
+	} else {
+		EncodeSixel(sb, img)
+	}
+
+	if w.Rows > 0 {
+		w.moveTo(sb, w.Rows-1, 0)
+	} else {
+		w.drawEOL(sb)
+	}
+	sb.WriteString(w.Status)
+	sb.WriteString("\033[K")
+
+	if w.DisplayConfig.SyncOutput {
+		sb.WriteString("\033[?2026l")
+	}
+
+	// The screen is no longer made of cells, but is marked as drawn.
+	w.screen = []string{}
+	w.screenCols = 0
+
+	io.WriteString(w.Output, sb.String())
+}
+
+// detectGraphics queries the terminal for graphics support, falling back to half-block
+// cells if the protocol of the mode is not supported or there is no answer.
+func (w *Window) detectGraphics() {
+	reply, ok := w.Terminal.Query(w.Output, GraphicsQuery, graphicsReplyDone, graphicsQueryTimeout)
+	if ok {
+		w.Graphics = ParseGraphicsReply(reply)
+	}
 
+	if (w.Mode == RenderKitty && w.Graphics.Kitty) || (w.Mode == RenderSixel && w.Graphics.Sixel) {
+		return
+	}
+
+	w.SetStatus(fmt.Sprintf("%s graphics not supported, using %s", w.Mode, RenderHalfBlock))
+	w.Mode = RenderHalfBlock
+}
+
+// drawFull clears the screen and writes every cell.
+func (w *Window) drawFull(sb *strings.Builder, cells []string, cols int) {
 	w.clear(sb)
 
-	for i := 0; i < 32; i++ {
-		for j := 0; j < 64; j++ {
-			if buffer[i*64+j] == 0xFF {
-				w.drawWhite(sb)
-			} else {
-				w.drawBlack(sb)
+	for i, cell := range cells {
+		sb.WriteString(cell)
+		if (i+1)%cols == 0 {
+			w.drawEOL(sb)
+		}
+	}
+
+	sb.WriteString(w.Status)
+}
+
+// drawChanged moves the cursor to and writes only the cells which differ from the screen.
+func (w *Window) drawChanged(sb *strings.Builder, cells []string, cols int) {
+	// The cursor position after the last write, -1 if it is not known to be in the display.
+	cursor := -1
+
+	for i, cell := range cells {
+		if cell == w.screen[i] {
+			continue
+		}
+
+		if i != cursor {
+			w.moveTo(sb, i/cols, i%cols)
+		}
+
+		sb.WriteString(cell)
+		cursor = i + 1
+		if cursor%cols == 0 {
+			cursor = -1
+		}
+	}
+
+	if w.Status != w.screenStatus {
+		w.moveTo(sb, len(cells)/cols, 0)
+		sb.WriteString(w.Status)
+		sb.WriteString("\033[K")
+	}
+}
+
+// moveTo moves the cursor to the cell at the zero-based row and column.
+func (w *Window) moveTo(sb *strings.Builder, row, col int) {
+	fmt.Fprintf(sb, "\033[%d;%dH", row+1, col+1)
+}
+
+// cells returns the cells drawn for the buffer in rows, and the number of columns.
+func (w *Window) cells(buffer []byte, width, height int) ([]string, int) {
+	cellWidth, cellHeight := w.cellSize()
+	scale := w.scale(width, height, cellWidth, cellHeight)
+	pixel := func(x, y int) byte {
+		if x >= width*scale || y >= height*scale {
+			return 0
+		}
+
+		return min(buffer[(y/scale)*width+x/scale], PaletteSize-1)
+	}
+
+	cols := (width*scale + cellWidth - 1) / cellWidth
+	rows := (height*scale + cellHeight - 1) / cellHeight
+	cells := make([]string, 0, cols*rows)
+
+	for y := 0; y < height*scale; y += cellHeight {
+		for x := 0; x < width*scale; x += cellWidth {
+			switch w.Mode {
+			case RenderHalfBlock:
+				cells = append(cells, w.halfBlock(pixel(x, y), pixel(x, y+1)))
+			case RenderBraille:
+				cells = append(cells, w.braille(pixel, x, y))
+			default:
+				cells = append(cells, w.block(pixel(x, y)))
 			}
 		}
-		w.drawEOL(sb)
 	}
 
-	fmt.Print(sb.String())
+	return cells, cols
+}
+
+// imageScale returns the number of image pixels per display pixel in the image modes. A
+// configured scale is for the low-resolution width as in the cell modes, otherwise the
+// largest scale which fits the text area in pixels, leaving a row for the status, is used.
+func (w *Window) imageScale(width, height int) int {
+	if w.DisplayConfig.Scale > 0 {
+		return max(w.DisplayConfig.Scale*w.DisplayConfig.Width/max(width, 1), 1)
+	}
+
+	if w.Graphics.PixelWidth == 0 || w.Graphics.PixelHeight == 0 || w.Rows == 0 {
+		return max(defaultImageScale*w.DisplayConfig.Width/max(width, 1), 1)
+	}
+
+	pixelHeight := w.Graphics.PixelHeight * (w.Rows - 1) / w.Rows
+
+	return max(min(w.Graphics.PixelWidth/width, pixelHeight/height), 1)
+}
+
+// cellSize returns the number of pixels drawn across and down each terminal cell.
+func (w *Window) cellSize() (int, int) {
+	switch w.Mode {
+	case RenderHalfBlock:
+		return 1, 2
+	case RenderBraille:
+		return 2, 4
+	}
+
+	return 1, 1
+}
+
+// scale returns the number of times each pixel is repeated. A configured scale is for the
+// low-resolution width, so high resolution keeps the same size on screen. Otherwise the
+// largest scale which fits the terminal, leaving a row for the status, is used.
+func (w *Window) scale(width, height, cellWidth, cellHeight int) int {
+	if w.DisplayConfig.Scale > 0 {
+		return max(w.DisplayConfig.Scale*w.DisplayConfig.Width/max(width, 1), 1)
+	}
+
+	if w.Cols == 0 || w.Rows == 0 {
+		return 1
+	}
+
+	return max(min(w.Cols*cellWidth/width, (w.Rows-1)*cellHeight/height), 1)
 }
 
+// updateSize reads the size of the terminal.
+func (w *Window) updateSize() {
+	cols, rows, err := w.Terminal.Size()
+	if err != nil {
+		cols, rows = 0, 0
+	}
+
+	w.Cols = cols
+	w.Rows = rows
+}
+
+// clear clears the screen in the colours of unset pixels and plane 1, which the cells
+// leave the terminal in so they can be drawn in any order.
 func (w *Window) clear(sb *strings.Builder) {
-	sb.WriteString("\033[38;5;15m\033[48;5;0m\033[H\033[2J")
+	sb.WriteString(w.fg[1])
+	sb.WriteString(w.bg[0])
+	sb.WriteString("\033[H\033[2J")
+}
+
+// updateColors sets the escape codes for the palette colours.
+func (w *Window) updateColors() {
+	for i, c := range w.DisplayConfig.Palette() {
+		if w.TrueColor {
+			r, g, b, _ := c.RGBA()
+			w.fg[i] = fmt.Sprintf("\033[38;2;%d;%d;%dm", r>>8, g>>8, b>>8)
+			w.bg[i] = fmt.Sprintf("\033[48;2;%d;%d;%dm", r>>8, g>>8, b>>8)
+		} else {
+			w.fg[i] = fmt.Sprintf("\033[38;5;%dm", NearestTermColor(c))
+			w.bg[i] = fmt.Sprintf("\033[48;5;%dm", NearestTermColor(c))
+		}
+	}
+}
+
+// block returns the cell for a pixel of the palette colour.
+func (w *Window) block(c byte) string {
+	switch c {
+	case 0:
+		return " "
+	case 1:
+		return "\033[7m \033[27m"
+	}
+
+	return w.bg[c] + " " + w.bg[0]
 }
 
-func (w *Window) drawWhite(sb *strings.Builder) {
-	sb.WriteString("\033[7m \033[27m")
+// halfBlocks are the characters for the top and bottom pixels of a cell, indexed by top | bottom<<1.
+var halfBlocks = [4]string{" ", "▀", "▄", "█"}
+
+// halfBlock returns the cell for a top and bottom pixel of the palette colours. Cells with
+// only unset and plane 1 pixels are drawn in the cleared colours without escape codes.
+func (w *Window) halfBlock(top, bottom byte) string {
+	switch {
+	case top <= 1 && bottom <= 1:
+		return halfBlocks[top|bottom<<1]
+	case top == bottom:
+		return w.fg[top] + halfBlocks[3] + w.fg[1]
+	}
+
+	return w.fg[top] + w.bg[bottom] + halfBlocks[1] + w.fg[1] + w.bg[0]
+}
+
+// brailleDots are the bits of the braille pattern for each pixel of a 2x4 cell, by row and column.
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
 }
 
-func (w *Window) drawBlack(sb *strings.Builder) {
-	sb.WriteString(" ")
+// braille returns the cell for the 2x4 pixels from x and y, with a dot for each set pixel.
+// A cell has a single colour, the most common of its set pixels.
+func (w *Window) braille(pixel func(x, y int) byte, x, y int) string {
+	r := rune(0x2800)
+	counts := [PaletteSize]int{}
+
+	for dy, row := range brailleDots {
+		for dx, dot := range row {
+			if c := pixel(x+dx, y+dy); c != 0 {
+				r |= dot
+				counts[c]++
+			}
+		}
+	}
+
+	c := 1
+	for i := 2; i < PaletteSize; i++ {
+		if counts[i] > counts[c] {
+			c = i
+		}
+	}
+
+	if c == 1 {
+		return string(r)
+	}
+
+	return w.fg[c] + string(r) + w.fg[1]
 }
 
 func (w *Window) drawEOL(sb *strings.Builder) {
-	sb.WriteString("\n")
+	sb.WriteString("\r\n")
 }
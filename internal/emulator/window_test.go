@@ -0,0 +1,365 @@
+package emulator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// benchmarkGames are small programs drawing like typical games: a static playfield with
+// a score, and sprites moving over it each frame.
+var benchmarkGames = []struct{ name, src string }{
+	{"ball", `
+: ball
+  0x60 0xF0 0xF0 0x60
+: main
+  v2 := 3
+  i := hex v2
+  v0 := 2
+  v1 := 1
+  sprite v0 v1 5
+  v0 := 56
+  sprite v0 v1 5
+  v0 := 8
+  v1 := 8
+  v3 := 1
+  v4 := 1
+  i := ball
+  sprite v0 v1 4
+  loop
+    v5 := 1
+    delay := v5
+    loop
+      v5 := delay
+      if v5 != 0 then
+    again
+    i := ball
+    sprite v0 v1 4
+    v0 += v3
+    v1 += v4
+    if v0 == 60 then v3 := 255
+    if v0 == 0 then v3 := 1
+    if v1 == 28 then v4 := 255
+    if v1 == 0 then v4 := 1
+    sprite v0 v1 4
+  again`},
+	{"invaders", `
+: alien
+  0x42 0x3C 0x7E 0x5A
+: main
+  v6 := 0
+  loop
+    clear
+    v1 := 2
+    loop
+      v0 := v6
+      loop
+        i := alien
+        sprite v0 v1 4
+        v0 += 8
+        if v0 < 48 then
+      again
+      v1 += 6
+      if v1 < 20 then
+    again
+    v6 += 1
+    if v6 == 16 then v6 := 0
+    v5 := 4
+    delay := v5
+    loop
+      v5 := delay
+      if v5 != 0 then
+    again
+  again`},
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
+// recordGame runs the game for the number of display frames and returns the frames it drew.
+func recordGame(tb testing.TB, src string, frames int) []RecordedFrame {
+	e := newTestEmulator(tb, CHIP8Config.Clone(), src)
+
+	recorder := NewFrameRecorder(0)
+	e.Display.Renderer = recorder
+	clock := NewVirtualClock(e.CPU.InstructionTimer.UpdateDelta)
+
+	for e.Display.Frame < frames {
+		delta := clock.Tick()
+		if err := e.CPU.Tick(delta); err != nil {
+			tb.Fatal(err)
+		}
+		e.Display.Tick(delta)
+	}
+
+	return recorder.Frames
+}
+
+// BenchmarkWindowRender reports the bytes written to the terminal per frame, drawing
+// every frame in full and drawing only the changed cells.
+func BenchmarkWindowRender(b *testing.B) {
+	for _, game := range benchmarkGames {
+		frames := recordGame(b, game.src, 120)
+
+		for _, mode := range []RenderMode{RenderBlock, RenderHalfBlock, RenderBraille} {
+			for _, full := range []bool{true, false} {
+				redraw := "diff"
+				if full {
+					redraw = "full"
+				}
+
+				b.Run(game.name+"/"+string(mode)+"/"+redraw, func(b *testing.B) {
+					config := CHIP8Config.Clone()
+					config.Display.Mode = mode
+					config.Display.Scale = 1
+
+					out := &countingWriter{}
+					w := NewWindow(config.Input, config.Display)
+					w.Output = out
+					w.FullRedraw = full
+
+					for i := 0; i < b.N; i++ {
+						f := frames[i%len(frames)]
+						w.Render(f.Buffer, f.Width, f.Height)
+					}
+
+					b.ReportMetric(float64(out.n)/float64(b.N), "B/frame")
+				})
+			}
+		}
+	}
+}
+
+// answeringTerminal is the output to a terminal which answers each write with the reply.
+type answeringTerminal struct {
+	out   bytes.Buffer
+	reply string
+	in    *os.File
+}
+
+func (a *answeringTerminal) Write(p []byte) (int, error) {
+	if a.reply != "" {
+		a.in.WriteString(a.reply)
+	}
+
+	return a.out.Write(p)
+}
+
+func TestDetectGraphics(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  RenderMode
+		reply string
+		want  RenderMode
+	}{
+		{"kitty", RenderKitty, "\033_Gi=31;OK\033\\\033[?62;22c", RenderKitty},
+		{"sixel", RenderSixel, "\033[?62;4c", RenderSixel},
+		{"kitty unsupported", RenderKitty, "\033[?62;4c", RenderHalfBlock},
+		{"no answer", RenderSixel, "", RenderHalfBlock},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, pw, err := os.Pipe()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer r.Close()
+			defer pw.Close()
+
+			config := CHIP8Config.Clone()
+			config.Display.Mode = tt.mode
+
+			out := &answeringTerminal{reply: tt.reply, in: pw}
+			w := NewWindow(config.Input, config.Display)
+			w.Terminal = NewTerminal(r)
+			w.Output = out
+			go w.Terminal.read()
+
+			w.detectGraphics()
+
+			assert.Equal(t, GraphicsQuery, out.out.String())
+			assert.Equal(t, tt.want, w.Mode)
+			if tt.want != tt.mode {
+				assert.Contains(t, w.Status, "not supported")
+			}
+		})
+	}
+}
+
+// gridCell is a character cell of a terminal with the colours it was written in.
+type gridCell struct {
+	Text    string
+	FG, BG  string
+	Reverse bool
+}
+
+// terminalGrid models the cells of a terminal, applying the cursor movement, erase and
+// colour escape codes the Window writes.
+type terminalGrid struct {
+	cells    [][]gridCell
+	row, col int
+	fg, bg   string
+	reverse  bool
+}
+
+func newTerminalGrid(rows, cols int) *terminalGrid {
+	g := &terminalGrid{cells: make([][]gridCell, rows)}
+	for i := range g.cells {
+		g.cells[i] = make([]gridCell, cols)
+	}
+	g.erase(0, 0, rows, cols)
+
+	return g
+}
+
+// erase blanks the cells from the row and column up to the end row and column in the
+// current background colour.
+func (g *terminalGrid) erase(row, col, endRow, endCol int) {
+	for r := row; r < endRow; r++ {
+		for c := col; c < endCol; c++ {
+			g.cells[r][c] = gridCell{Text: " ", BG: g.bg}
+		}
+	}
+}
+
+func (g *terminalGrid) Write(p []byte) (int, error) {
+	for i := 0; i < len(p); {
+		switch {
+		case p[i] == 0x1B && i+1 < len(p) && p[i+1] == '[':
+			j := i + 2
+			for j < len(p) && (p[j] < 0x40 || p[j] > 0x7E) {
+				j++
+			}
+			if j == len(p) {
+				return 0, fmt.Errorf("unterminated escape %q", p[i:])
+			}
+
+			if err := g.escape(string(p[i+2:j]), p[j]); err != nil {
+				return 0, err
+			}
+			i = j + 1
+		case p[i] == '\r':
+			g.col = 0
+			i++
+		case p[i] == '\n':
+			g.row++
+			i++
+		default:
+			r, size := utf8.DecodeRune(p[i:])
+			g.cells[g.row][g.col] = gridCell{Text: string(r), FG: g.fg, BG: g.bg, Reverse: g.reverse}
+			g.col++
+			i += size
+		}
+	}
+
+	return len(p), nil
+}
+
+func (g *terminalGrid) escape(params string, final byte) error {
+	cols := len(g.cells[0])
+
+	switch {
+	case strings.HasPrefix(params, "?"):
+		// Synchronized output does not change the cells.
+	case final == 'H':
+		g.row, g.col = 0, 0
+		if params != "" {
+			row, col, _ := strings.Cut(params, ";")
+			r, _ := strconv.Atoi(row)
+			c, _ := strconv.Atoi(col)
+			g.row, g.col = r-1, c-1
+		}
+	case final == 'J' && params == "2":
+		g.erase(0, 0, len(g.cells), cols)
+	case final == 'K':
+		g.erase(g.row, g.col, g.row+1, cols)
+	case final == 'm':
+		g.sgr(params)
+	default:
+		return fmt.Errorf("unexpected escape %q%c", params, final)
+	}
+
+	return nil
+}
+
+// sgr applies the reverse and palette colour codes.
+func (g *terminalGrid) sgr(params string) {
+	switch {
+	case params == "7":
+		g.reverse = true
+	case params == "27":
+		g.reverse = false
+	case strings.HasPrefix(params, "38;"):
+		g.fg = params
+	case strings.HasPrefix(params, "48;"):
+		g.bg = params
+	}
+}
+
+// TestWindowDiffMatchesFull checks that drawing only the changed cells leaves the terminal
+// showing the same cells as drawing every frame in full.
+func TestWindowDiffMatchesFull(t *testing.T) {
+	frames := []RecordedFrame{}
+	for _, game := range benchmarkGames {
+		frames = append(frames, recordGame(t, game.src, 30)...)
+	}
+
+	// Frames of every palette colour, with the status changing between them.
+	rng := NewRNG(1)
+	for i := range 8 {
+		buffer := make([]byte, 64*32)
+		for j := range buffer {
+			buffer[j] = rng.Byte() % PaletteSize
+		}
+		frames = append(frames, RecordedFrame{Width: 64, Height: 32, Buffer: buffer, Status: fmt.Sprintf("frame %d", i/3)})
+	}
+
+	for _, mode := range []RenderMode{RenderBlock, RenderHalfBlock, RenderBraille} {
+		for _, trueColor := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s truecolor=%v", mode, trueColor), func(t *testing.T) {
+				render := func(full bool) (*Window, *terminalGrid) {
+					config := CHIP8Config.Clone()
+					config.Display.Mode = mode
+					config.Display.Scale = 1
+
+					grid := newTerminalGrid(40, 80)
+					w := NewWindow(config.Input, config.Display)
+					w.Output = grid
+					w.FullRedraw = full
+					w.TrueColor = trueColor
+					w.updateColors()
+
+					return w, grid
+				}
+
+				full, fullGrid := render(true)
+				diff, diffGrid := render(false)
+
+				for i, f := range frames {
+					full.SetStatus(f.Status)
+					full.Render(f.Buffer, f.Width, f.Height)
+					diff.SetStatus(f.Status)
+					diff.Render(f.Buffer, f.Width, f.Height)
+
+					if !assert.Equal(t, fullGrid.cells, diffGrid.cells, "frame %d", i) {
+						return
+					}
+				}
+			})
+		}
+	}
+}
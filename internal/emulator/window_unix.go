@@ -0,0 +1,14 @@
+//go:build unix
+
+package emulator
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize sends to the channel when the terminal is resized.
+func notifyResize(c chan os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
@@ -0,0 +1,7 @@
+package emulator
+
+import "os"
+
+// notifyResize does nothing, as Windows has no resize signal. The size of the console is
+// read when the window is initialised.
+func notifyResize(c chan os.Signal) {}